@@ -0,0 +1,395 @@
+// Package analysis works out what the names in a program refer to without
+// running it. It is shared by the language server and `loop vet`.
+package analysis
+
+import (
+	"github.com/kanersps/loop/ast"
+	"github.com/kanersps/loop/object/builtins"
+)
+
+// Position is a 1-based line and column, counted in runes like the lexer.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) Before(other Position) bool {
+	return p.Line < other.Line || (p.Line == other.Line && p.Column < other.Column)
+}
+
+type BindingKind int
+
+const (
+	Variable BindingKind = iota
+	Parameter
+	Function
+	Constant
+	Type
+)
+
+// Binding is a name introduced by a var or const statement, a function or
+// struct declaration, a function parameter, a pattern or a for loop.
+type Binding struct {
+	Name  *ast.Identifier
+	Kind  BindingKind
+	Value ast.Expression // the value of a variable or function, nil for parameters and structs
+	Scope *Scope
+	Uses  []*ast.Identifier
+}
+
+// Scope holds the bindings of the program, of a single function or of a
+// match arm. Other blocks do not introduce scopes since the evaluator runs
+// them in the enclosing environment.
+type Scope struct {
+	Outer    *Scope
+	Node     ast.Node // *ast.Program, *ast.FunctionLiteral or the body of a match arm
+	Start    Position
+	End      Position
+	Bindings []*Binding
+}
+
+// Contains reports whether pos lies inside the scope's source range.
+func (s *Scope) Contains(pos Position) bool {
+	return !pos.Before(s.Start) && !s.End.Before(pos)
+}
+
+// Info is the result of resolving a program.
+type Info struct {
+	Scopes     []*Scope
+	Bindings   map[*ast.Identifier]*Binding // keyed by the declaring identifier
+	Uses       map[*ast.Identifier]*Binding // keyed by the referring identifier
+	Builtins   []*ast.Identifier            // references to builtin functions
+	Unresolved []*ast.Identifier            // references to names that are never bound
+
+	templates map[*ast.Identifier]*ast.TemplateLiteral
+}
+
+// Resolve binds every identifier in program to its declaration. A reference
+// inside a function may refer to a variable declared after the function,
+// since the body only runs once the function is called.
+func Resolve(program *ast.Program) *Info {
+	r := &resolver{info: &Info{
+		Bindings:  map[*ast.Identifier]*Binding{},
+		Uses:      map[*ast.Identifier]*Binding{},
+		templates: map[*ast.Identifier]*ast.TemplateLiteral{},
+	}, methods: map[*ast.FunctionLiteral]bool{}}
+
+	scope := r.newScope(nil, program)
+	scope.Start = Position{Line: 1, Column: 1}
+	scope.End = tokenEnd(program.End.Line, program.End.Column, "")
+
+	r.resolveScope(scope, func() {
+		ast.Inspect(program, r.visit)
+	})
+
+	return r.info
+}
+
+// PositionOf returns where an identifier appears. Identifiers inside a
+// template string are reported at the string itself.
+func (info *Info) PositionOf(ident *ast.Identifier) Position {
+	if template, ok := info.templates[ident]; ok {
+		return Position{Line: template.Token.Line, Column: template.Token.Column}
+	}
+
+	return Position{Line: ident.Token.Line, Column: ident.Token.Column}
+}
+
+// IdentifierAt returns the identifier covering pos, if there is one.
+func (info *Info) IdentifierAt(pos Position) *ast.Identifier {
+	matches := func(ident *ast.Identifier) bool {
+		if _, ok := info.templates[ident]; ok {
+			return false
+		}
+
+		start := Position{Line: ident.Token.Line, Column: ident.Token.Column}
+		return !pos.Before(start) && pos.Before(tokenEnd(start.Line, start.Column, ident.Value))
+	}
+
+	for ident := range info.Bindings {
+		if matches(ident) {
+			return ident
+		}
+	}
+
+	for ident := range info.Uses {
+		if matches(ident) {
+			return ident
+		}
+	}
+
+	for _, ident := range append(info.Builtins, info.Unresolved...) {
+		if matches(ident) {
+			return ident
+		}
+	}
+
+	return nil
+}
+
+// ScopeAt returns the innermost scope containing pos.
+func (info *Info) ScopeAt(pos Position) *Scope {
+	var innermost *Scope
+
+	for _, scope := range info.Scopes {
+		if scope.Contains(pos) && (innermost == nil || innermost.Start.Before(scope.Start)) {
+			innermost = scope
+		}
+	}
+
+	return innermost
+}
+
+func tokenEnd(line int, column int, value string) Position {
+	return Position{Line: line, Column: column + len([]rune(value))}
+}
+
+type resolver struct {
+	info     *Info
+	scope    *Scope
+	pending  []*ast.FunctionLiteral
+	methods  map[*ast.FunctionLiteral]bool
+	template *ast.TemplateLiteral
+}
+
+func (r *resolver) newScope(outer *Scope, node ast.Node) *Scope {
+	scope := &Scope{Outer: outer, Node: node}
+	r.info.Scopes = append(r.info.Scopes, scope)
+
+	return scope
+}
+
+// resolveScope walks a scope and then the functions defined in it, once all
+// of the scope's own bindings are known.
+func (r *resolver) resolveScope(scope *Scope, walk func()) {
+	outer, pending := r.scope, r.pending
+	r.scope, r.pending = scope, nil
+
+	walk()
+
+	for _, fn := range r.pending {
+		r.resolveFunction(fn)
+	}
+
+	r.scope, r.pending = outer, pending
+}
+
+func (r *resolver) resolveFunction(fn *ast.FunctionLiteral) {
+	scope := r.newScope(r.scope, fn)
+	scope.Start = Position{Line: fn.Token.Line, Column: fn.Token.Column}
+
+	if fn.Body != nil {
+		scope.End = tokenEnd(fn.Body.End.Line, fn.Body.End.Column, fn.Body.End.Value)
+	}
+
+	r.resolveScope(scope, func() {
+		// The name of a function expression is bound inside the function.
+		// Declarations and methods are bound elsewhere.
+		if fn.Name != nil && r.info.Bindings[fn.Name] == nil && !r.methods[fn] {
+			r.declare(fn.Name, Function, fn)
+		}
+
+		for i, parameter := range fn.Parameters {
+			if i < len(fn.Defaults) {
+				ast.Inspect(fn.Defaults[i], r.visit)
+			}
+
+			if i < len(fn.Patterns) && fn.Patterns[i] != nil {
+				r.declarePattern(fn.Patterns[i], Parameter)
+			} else {
+				r.declare(parameter, Parameter, nil)
+			}
+		}
+
+		r.declare(fn.Rest, Parameter, nil)
+
+		ast.Inspect(fn.Body, r.visit)
+	})
+}
+
+// resolveArm resolves a match arm in a scope of its own, which holds the
+// names its pattern binds. The scope reaches to the end of the match; a
+// later arm starts a scope nested inside it, which ScopeAt prefers.
+func (r *resolver) resolveArm(match *ast.MatchExpression, arm *ast.MatchArm) {
+	start := ast.FirstToken(arm.Pattern)
+
+	scope := r.newScope(r.scope, arm.Body)
+	scope.Start = Position{Line: start.Line, Column: start.Column}
+	scope.End = tokenEnd(match.End.Line, match.End.Column, match.End.Value)
+
+	r.resolveScope(scope, func() {
+		r.declarePattern(arm.Pattern, Variable)
+		ast.Inspect(arm.Body, r.visit)
+	})
+}
+
+func (r *resolver) visit(node ast.Node) bool {
+	switch node := node.(type) {
+	case *ast.Program:
+		r.hoist(node.Statements)
+	case *ast.BlockStatement:
+		r.hoist(node.Statements)
+	case *ast.FunctionStatement:
+		r.pending = append(r.pending, node.Function)
+		return false
+	case *ast.VariableStatement:
+		// The value is evaluated before the name is bound, so `var x = x`
+		// refers to an earlier x.
+		kind := Variable
+		if node.Constant() {
+			kind = Constant
+		}
+
+		ast.Inspect(node.Value, r.visit)
+		r.declare(node.Name, kind, node.Value)
+		r.declarePattern(node.Pattern, kind)
+		return false
+	case *ast.FunctionLiteral:
+		r.pending = append(r.pending, node)
+		return false
+	case *ast.StructStatement:
+		// Fields and methods are reached through an instance, so only the
+		// struct itself is bound. The defaults are evaluated where the struct
+		// is declared.
+		r.declare(node.Name, Type, nil)
+		for _, field := range node.Fields() {
+			ast.Inspect(field.Value, r.visit)
+		}
+		for _, method := range node.Methods() {
+			r.methods[method] = true
+			r.pending = append(r.pending, method)
+		}
+		return false
+	case *ast.MemberExpression:
+		ast.Inspect(node.Object, r.visit)
+		return false
+	case *ast.ForLiteral:
+		ast.Inspect(node.Iterable, r.visit)
+		r.declarePattern(node.Pattern, Variable)
+		ast.Inspect(node.Body, r.visit)
+		return false
+	case *ast.MatchExpression:
+		ast.Inspect(node.Value, r.visit)
+		for _, arm := range node.Arms {
+			r.resolveArm(node, arm)
+		}
+		return false
+	case *ast.TemplateLiteral:
+		outer := r.template
+		r.template = node
+		for _, part := range node.Parts {
+			ast.Inspect(part, r.visit)
+		}
+		r.template = outer
+		return false
+	case *ast.Identifier:
+		r.use(node)
+	}
+
+	return true
+}
+
+// hoist declares the functions of a block up front, like the evaluator.
+func (r *resolver) hoist(statements []ast.Statement) {
+	for _, statement := range statements {
+		if declaration, ok := statement.(*ast.FunctionStatement); ok {
+			r.declare(declaration.Function.Name, Function, declaration.Function)
+		}
+	}
+}
+
+// declarePattern declares the names a pattern binds. Hash pattern keys are
+// literals and the _ wildcard binds nothing.
+func (r *resolver) declarePattern(pattern ast.Expression, kind BindingKind) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value != "_" {
+			r.declare(pattern, kind, nil)
+		}
+	case *ast.SpreadExpression:
+		r.declarePattern(pattern.Value, kind)
+	case *ast.ArrayLiteral:
+		for _, element := range pattern.Elements {
+			r.declarePattern(element, kind)
+		}
+	case *ast.HashLiteral:
+		for _, key := range pattern.Keys {
+			r.declarePattern(pattern.Pairs[key], kind)
+		}
+	}
+}
+
+func (r *resolver) declare(name *ast.Identifier, kind BindingKind, value ast.Expression) {
+	if name == nil || name.Value == "" {
+		return
+	}
+
+	binding := &Binding{Name: name, Kind: kind, Value: value, Scope: r.scope}
+	r.scope.Bindings = append(r.scope.Bindings, binding)
+	r.info.Bindings[name] = binding
+}
+
+func (r *resolver) use(ident *ast.Identifier) {
+	if r.template != nil {
+		r.info.templates[ident] = r.template
+	}
+
+	binding := r.lookup(ident)
+
+	switch {
+	case binding != nil:
+		binding.Uses = append(binding.Uses, ident)
+		r.info.Uses[ident] = binding
+	case builtins.Functions[ident.Value] != nil:
+		r.info.Builtins = append(r.info.Builtins, ident)
+	case ident.Value == "self" && r.inMethod():
+		// Bound to the instance when the method is called.
+	default:
+		r.info.Unresolved = append(r.info.Unresolved, ident)
+	}
+}
+
+// inMethod reports whether the current scope is a method or a function
+// nested in one.
+func (r *resolver) inMethod() bool {
+	for scope := r.scope; scope != nil; scope = scope.Outer {
+		if fn, ok := scope.Node.(*ast.FunctionLiteral); ok && r.methods[fn] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lookup finds the binding an identifier refers to. In its own scope that is
+// the latest binding so far. In an enclosing scope it is the latest binding
+// before the reference, or else the first one after it.
+func (r *resolver) lookup(ident *ast.Identifier) *Binding {
+	pos := r.info.PositionOf(ident)
+
+	for i := len(r.scope.Bindings) - 1; i >= 0; i-- {
+		if r.scope.Bindings[i].Name.Value == ident.Value {
+			return r.scope.Bindings[i]
+		}
+	}
+
+	for scope := r.scope.Outer; scope != nil; scope = scope.Outer {
+		var found *Binding
+
+		for _, binding := range scope.Bindings {
+			if binding.Name.Value != ident.Value {
+				continue
+			}
+
+			if found == nil || r.info.PositionOf(binding.Name).Before(pos) {
+				found = binding
+			}
+		}
+
+		if found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package analysis
+
+import (
+	"github.com/kanersps/loop/ast"
+	"github.com/kanersps/loop/parser"
+	"github.com/kanersps/loop/parser/lexer"
+	"testing"
+)
+
+func resolve(t *testing.T, input string) *Info {
+	p := parser.Create(lexer.Create(input))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return Resolve(program)
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		input string
+		use   Position // the reference to look up
+		def   Position // where it should resolve to, zero when unresolved
+	}{
+		{"var a = 1; a", Position{1, 12}, Position{1, 5}},
+		{"var a = 1; var a = a + 1; a", Position{1, 20}, Position{1, 5}},
+		{"var a = 1; var a = a + 1; a", Position{1, 27}, Position{1, 16}},
+		{"var f = func(a) { a }; var a = 2", Position{1, 19}, Position{1, 14}},
+		{"var f = func() { g() }; var g = 1", Position{1, 18}, Position{1, 29}},
+		{"var f = func() { f() }", Position{1, 18}, Position{1, 5}},
+		{"missing", Position{1, 1}, Position{}},
+		{"f(); func f() { g() }; func g() {}", Position{1, 1}, Position{1, 11}},
+		{"f(); func f() { g() }; func g() {}", Position{1, 17}, Position{1, 29}},
+		{"var h = func() { return i(); func i() {} }", Position{1, 25}, Position{1, 35}},
+		{"var a = 1; \"${a}\"", Position{1, 12}, Position{1, 5}},
+	}
+
+	for _, tc := range tests {
+		info := resolve(t, tc.input)
+
+		var use *ast.Identifier
+		for ident := range info.Uses {
+			if info.PositionOf(ident) == tc.use {
+				use = ident
+			}
+		}
+
+		if tc.def == (Position{}) {
+			if use != nil || len(info.Unresolved) != 1 {
+				t.Errorf("%q: expected an unresolved reference", tc.input)
+			}
+			continue
+		}
+
+		if use == nil {
+			t.Errorf("%q: no reference at %v", tc.input, tc.use)
+			continue
+		}
+
+		if def := info.PositionOf(info.Uses[use].Name); def != tc.def {
+			t.Errorf("%q: wrong definition. expected=%v. got=%v", tc.input, tc.def, def)
+		}
+	}
+}
+
+func TestResolve_Builtins(t *testing.T) {
+	info := resolve(t, "len([1])")
+
+	if len(info.Builtins) != 1 || len(info.Unresolved) != 0 {
+		t.Errorf("len should resolve to a builtin. builtins=%d. unresolved=%d", len(info.Builtins), len(info.Unresolved))
+	}
+}
+
+func TestScopeAt(t *testing.T) {
+	info := resolve(t, "var a = 1\nvar f = func(b) {\n    b\n}\n")
+
+	scope := info.ScopeAt(Position{3, 5})
+	if _, ok := scope.Node.(*ast.FunctionLiteral); !ok {
+		t.Fatalf("expected the function scope. got=%T", scope.Node)
+	}
+
+	if scope.Bindings[0].Name.Value != "b" || scope.Outer.Bindings[0].Name.Value != "a" {
+		t.Errorf("wrong bindings in scope")
+	}
+
+	if _, ok := info.ScopeAt(Position{1, 1}).Node.(*ast.Program); !ok {
+		t.Errorf("expected the program scope")
+	}
+}
@@ -0,0 +1,237 @@
+package analysis
+
+import (
+	"fmt"
+	"github.com/kanersps/loop/ast"
+	"github.com/kanersps/loop/object/builtins"
+	"sort"
+	"strings"
+)
+
+// Diagnostic is a problem found in a program without running it.
+type Diagnostic struct {
+	Position
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s", d.Line, d.Column, d.Message)
+}
+
+// Vet reports likely mistakes in a program: unknown identifiers, calls with
+// the wrong number of arguments, unused variables, unreachable code,
+// variables that shadow the outer variable they are computed from, constants
+// that are declared again and declarations that shadow builtins.
+func Vet(program *ast.Program) []Diagnostic {
+	v := &vet{info: Resolve(program)}
+
+	v.unknownIdentifiers()
+	v.unusedVariables()
+	v.redeclarations()
+
+	ast.Inspect(program, v.visit)
+
+	sort.SliceStable(v.diagnostics, func(i, j int) bool {
+		return v.diagnostics[i].Before(v.diagnostics[j].Position)
+	})
+
+	return v.diagnostics
+}
+
+type vet struct {
+	info        *Info
+	diagnostics []Diagnostic
+}
+
+func (v *vet) report(pos Position, format string, a ...interface{}) {
+	v.diagnostics = append(v.diagnostics, Diagnostic{Position: pos, Message: fmt.Sprintf(format, a...)})
+}
+
+func (v *vet) unknownIdentifiers() {
+	for _, ident := range v.info.Unresolved {
+		v.report(v.info.PositionOf(ident), "unknown identifier %s", ident.Value)
+	}
+}
+
+// unusedVariables reports variables declared in a function that are never
+// read. Declaring a variable again assigns to it, so a name counts as used
+// when any of its declarations in the scope is used. Top-level variables may
+// be used by whoever loads the file and are left alone.
+func (v *vet) unusedVariables() {
+	for _, scope := range v.info.Scopes {
+		if scope.Outer == nil {
+			continue
+		}
+
+		used := map[string]bool{}
+		for _, binding := range scope.Bindings {
+			if len(binding.Uses) != 0 {
+				used[binding.Name.Value] = true
+			}
+		}
+
+		for _, binding := range scope.Bindings {
+			name := binding.Name.Value
+			if binding.Kind == Parameter || binding.Kind == Function || used[name] || strings.HasPrefix(name, "_") {
+				continue
+			}
+
+			// Only report the first declaration of a name.
+			used[name] = true
+			v.report(v.info.PositionOf(binding.Name), "%s declared but not used", name)
+		}
+	}
+}
+
+// redeclarations reports names bound again after being declared const in
+// the same scope, and top-level declarations named after a builtin, which
+// the evaluator rejects unless shadowing is allowed.
+func (v *vet) redeclarations() {
+	for _, scope := range v.info.Scopes {
+		constants := map[string]bool{}
+
+		for _, binding := range scope.Bindings {
+			name := binding.Name.Value
+			pos := v.info.PositionOf(binding.Name)
+
+			if constants[name] {
+				v.report(pos, "cannot reassign constant %s", name)
+			}
+
+			if binding.Kind == Constant {
+				constants[name] = true
+			}
+
+			if builtins.Functions[name] != nil && scope.Outer == nil {
+				v.report(pos, "%s shadows the builtin %s", name, name)
+			}
+		}
+	}
+}
+
+func (v *vet) visit(node ast.Node) bool {
+	switch node := node.(type) {
+	case *ast.Program:
+		v.unreachable(node.Statements)
+	case *ast.BlockStatement:
+		v.unreachable(node.Statements)
+	case *ast.CallExpression:
+		v.arity(node)
+	case *ast.VariableStatement:
+		v.shadowing(node)
+	}
+
+	return true
+}
+
+// unreachable reports the first statement following a return. Function
+// declarations are hoisted, so they are reachable wherever they are.
+func (v *vet) unreachable(statements []ast.Statement) {
+	returned := false
+
+	for _, statement := range statements {
+		switch statement.(type) {
+		case *ast.FunctionStatement:
+			continue
+		case *ast.ReturnStatement:
+			if !returned {
+				returned = true
+				continue
+			}
+		}
+
+		if returned {
+			v.report(startOf(statement), "unreachable code after return")
+			return
+		}
+	}
+}
+
+// arity checks calls to functions whose definition is known: function
+// literals called directly and variables bound to a function literal.
+func (v *vet) arity(call *ast.CallExpression) {
+	var fn *ast.FunctionLiteral
+	name := "function"
+
+	switch callee := call.Function.(type) {
+	case *ast.FunctionLiteral:
+		fn = callee
+	case *ast.Identifier:
+		binding := v.info.Uses[callee]
+		if binding == nil {
+			return
+		}
+
+		fn, _ = binding.Value.(*ast.FunctionLiteral)
+		name = callee.Value
+	}
+
+	if fn == nil {
+		return
+	}
+
+	// The number of arguments a spread passes is only known at runtime.
+	for _, argument := range call.Arguments {
+		if _, ok := argument.(*ast.SpreadExpression); ok {
+			return
+		}
+	}
+
+	required := 0
+	for i := range fn.Parameters {
+		if i >= len(fn.Defaults) || fn.Defaults[i] == nil {
+			required = i + 1
+		}
+	}
+
+	got := len(call.Arguments)
+	if got >= required && (fn.Rest != nil || got <= len(fn.Parameters)) {
+		return
+	}
+
+	expected := fmt.Sprintf("%d", len(fn.Parameters))
+
+	switch {
+	case fn.Rest != nil:
+		expected = fmt.Sprintf("at least %d", required)
+	case required != len(fn.Parameters):
+		expected = fmt.Sprintf("%d to %d", required, len(fn.Parameters))
+	}
+
+	v.report(startOf(call), "wrong number of arguments to %s. expected=%s. got=%d", name, expected, got)
+}
+
+// shadowing reports `var x = x + 1` inside a function when x is a variable
+// two or more scopes out. Declaring a name also assigns it in the directly
+// enclosing environment, but not further out, so there the statement only
+// declares a new local x and leaves the outer one unchanged.
+func (v *vet) shadowing(statement *ast.VariableStatement) {
+	binding := v.info.Bindings[statement.Name]
+	if binding == nil || binding.Scope.Outer == nil {
+		return
+	}
+
+	ast.Inspect(statement.Value, func(node ast.Node) bool {
+		if _, ok := node.(*ast.FunctionLiteral); ok {
+			return false
+		}
+
+		ident, ok := node.(*ast.Identifier)
+		if !ok || ident.Value != statement.Name.Value {
+			return true
+		}
+
+		if outer := v.info.Uses[ident]; outer != nil && outer.Scope != binding.Scope && outer.Scope != binding.Scope.Outer {
+			v.report(v.info.PositionOf(statement.Name), "var %s shadows the outer %s it is computed from", ident.Value, ident.Value)
+			return false
+		}
+
+		return true
+	})
+}
+
+func startOf(node ast.Node) Position {
+	token := ast.FirstToken(node)
+
+	return Position{Line: token.Line, Column: token.Column}
+}
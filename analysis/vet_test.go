@@ -0,0 +1,76 @@
+package analysis
+
+import (
+	"github.com/kanersps/loop/parser"
+	"github.com/kanersps/loop/parser/lexer"
+	"testing"
+)
+
+func TestVet(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"var a = 1; a", nil},
+		{"missing + 1", []string{"1:1: unknown identifier missing"}},
+		{`"${missing}"`, []string{"1:1: unknown identifier missing"}},
+		{"len([])", nil},
+		{"var f = func(a, b) { a + b }; f(1)", []string{"1:31: wrong number of arguments to f. expected=2. got=1"}},
+		{"var f = func(a, b = 1) { a + b }; f(1); f(1, 2)", nil},
+		{"var f = func(a, b = 1) { a + b }; f()", []string{"1:35: wrong number of arguments to f. expected=1 to 2. got=0"}},
+		{"var f = func(a, ...rest) { rest }; f(1, 2, 3)", nil},
+		{"var f = func(a, b) { a + b }; f(...[1, 2])", nil},
+		{"func(a) { a }(1, 2)", []string{"1:1: wrong number of arguments to function. expected=1. got=2"}},
+		{"var f = func() { var a = 1; 2 }", []string{"1:22: a declared but not used"}},
+		{"var f = func() { var _a = 1; 2 }", nil},
+		{"var f = func() { var i = 0; while (i < 3) { var i = i + 1 } }", nil},
+		{"var f = func() { return 1; 2 }", []string{"1:28: unreachable code after return"}},
+		{"var f = func() { return g(); func g() { 1 } }", nil},
+		{"f(1); func f(a) { a }", nil},
+		{"var f = func fact(n) { fact(n - 1) }; f(1); fact(2)", []string{"1:45: unknown identifier fact"}},
+		{"func f(a) { a }; f()", []string{"1:18: wrong number of arguments to f. expected=1. got=0"}},
+		{"func f() { var x = 1; 2 }", []string{"1:16: x declared but not used"}},
+		{"var x = 1; var f = func() { var x = x + 1; x }", nil},
+		{"var x = 1; var f = func() { var g = func() { var x = x + 1; x }; g() }", []string{"1:50: var x shadows the outer x it is computed from"}},
+		{"var x = 1; var f = func() { match (1) { _ => { var x = x + 1; x } } }", []string{"1:52: var x shadows the outer x it is computed from"}},
+		{"var x = 1; var x = x + 1", nil},
+		{"match ([1]) { [a, ...rest] => a + len(rest), _ => missing }", []string{"1:51: unknown identifier missing"}},
+		{"var f = func(x) { match (x) { [a, b] => a } }", []string{"1:35: b declared but not used"}},
+		{"match ([1]) { [a] => a }; a", []string{"1:27: unknown identifier a"}},
+		{"const a = 1; match ([1]) { [a] => a }", nil},
+		{"var f = func(xs) { var [a, ...rest] = xs; a }", []string{"1:31: rest declared but not used"}},
+		{"var f = func([a, b], {name}) { a + name }", nil},
+		{"var [a, b] = [1, 2]; a + b + c", []string{"1:30: unknown identifier c"}},
+		{"const a = 1; var a = 2", []string{"1:18: cannot reassign constant a"}},
+		{"const a = 1; var f = func() { var a = 2; a }", nil},
+		{"var f = func() { const a = 1; 2 }", []string{"1:24: a declared but not used"}},
+		{"struct P { var x = 0; func get() { self.x + y } }; P().get()", []string{"1:45: unknown identifier y"}},
+		{"self.x; var f = func() { struct Q {}; 1 }", []string{"1:1: unknown identifier self", "1:33: Q declared but not used"}},
+		{"for ([k, v] in {}) { k + v }; k + x", []string{"1:35: unknown identifier x"}},
+		{"var f = func() { for (x in [1]) { 1 } }", []string{"1:23: x declared but not used"}},
+		{"var len = 1; func print() {}", []string{"1:5: len shadows the builtin len", "1:19: print shadows the builtin print"}},
+		{"var f = func(set) { var input = set; input }; f(1)", nil},
+	}
+
+	for _, tc := range tests {
+		p := parser.Create(lexer.Create(tc.input))
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", tc.input, p.Errors())
+		}
+
+		diagnostics := Vet(program)
+
+		if len(diagnostics) != len(tc.expected) {
+			t.Errorf("wrong diagnostics for %q. expected=%q. got=%v", tc.input, tc.expected, diagnostics)
+			continue
+		}
+
+		for i, diagnostic := range diagnostics {
+			if diagnostic.String() != tc.expected[i] {
+				t.Errorf("wrong diagnostic for %q. expected=%q. got=%q", tc.input, tc.expected[i], diagnostic.String())
+			}
+		}
+	}
+}
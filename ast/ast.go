@@ -50,17 +50,91 @@ func (oe *InfixExpression) String() string {
 
 type Program struct {
 	Statements []Statement
+	End        tokens.Token // the EOF token
 }
 
 type VariableStatement struct {
 	Token tokens.Token
 	Name  *Identifier
-	Value Expression
+	// Pattern destructures the value, as in `var [a, b] = pair`. Name is
+	// nil when it is set.
+	Pattern Expression
+	Value   Expression
 }
 
 func (vs *VariableStatement) statementNode()     {}
 func (vs *VariableStatement) TokenValue() string { return vs.Token.Value }
 
+// Constant reports whether the statement is a const declaration.
+func (vs *VariableStatement) Constant() bool {
+	return vs.Token.TokenType == tokens.Const
+}
+
+// FunctionStatement declares a named function, `func name(a) { }`. It is
+// bound before the other statements of its block run.
+type FunctionStatement struct {
+	Token    tokens.Token // the 'func' token
+	Function *FunctionLiteral
+}
+
+func (fs *FunctionStatement) statementNode()     {}
+func (fs *FunctionStatement) TokenValue() string { return fs.Token.Value }
+func (fs *FunctionStatement) String() string     { return fs.Function.String() }
+
+// StructStatement declares a user type, `struct Point { var x = 0 }`. Its
+// body only holds var statements, which declare the fields along with their
+// defaults, and function declarations, which declare the methods.
+type StructStatement struct {
+	Token tokens.Token // the 'struct' token
+	Name  *Identifier
+	Body  *BlockStatement
+}
+
+func (ss *StructStatement) statementNode()     {}
+func (ss *StructStatement) TokenValue() string { return ss.Token.Value }
+func (ss *StructStatement) String() string {
+	return "struct " + ss.Name.String() + " " + ss.Body.String()
+}
+
+// Fields returns the var statements declaring the fields of the struct.
+func (ss *StructStatement) Fields() []*VariableStatement {
+	var fields []*VariableStatement
+
+	for _, statement := range ss.Body.Statements {
+		if field, ok := statement.(*VariableStatement); ok {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// Methods returns the functions declared in the body of the struct.
+func (ss *StructStatement) Methods() []*FunctionLiteral {
+	var methods []*FunctionLiteral
+
+	for _, statement := range ss.Body.Statements {
+		if method, ok := statement.(*FunctionStatement); ok {
+			methods = append(methods, method.Function)
+		}
+	}
+
+	return methods
+}
+
+// AssignStatement sets a field of an instance, `self.x = 1`.
+type AssignStatement struct {
+	Token  tokens.Token // the '=' token
+	Target *MemberExpression
+	Value  Expression
+}
+
+func (as *AssignStatement) statementNode()     {}
+func (as *AssignStatement) TokenValue() string { return as.Token.Value }
+func (as *AssignStatement) String() string {
+	return as.Target.String() + " = " + as.Value.String() + ";"
+}
+
 type ReturnStatement struct {
 	Token       tokens.Token
 	ReturnValue Expression
@@ -69,6 +143,18 @@ type ReturnStatement struct {
 func (vs *ReturnStatement) statementNode()     {}
 func (vs *ReturnStatement) TokenValue() string { return vs.Token.Value }
 
+// YieldStatement hands a value to whoever consumes the generator it is in.
+type YieldStatement struct {
+	Token tokens.Token // the 'yield' token
+	Value Expression
+}
+
+func (ys *YieldStatement) statementNode()     {}
+func (ys *YieldStatement) TokenValue() string { return ys.Token.Value }
+func (ys *YieldStatement) String() string {
+	return "yield " + ys.Value.String() + ";"
+}
+
 type Identifier struct {
 	Token tokens.Token
 	Value string
@@ -106,7 +192,11 @@ func (il *IntegerLiteral) String() string     { return il.Token.Value }
 func (v *VariableStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(v.TokenValue() + " ")
-	out.WriteString(v.Name.String())
+	if v.Pattern != nil {
+		out.WriteString(v.Pattern.String())
+	} else {
+		out.WriteString(v.Name.String())
+	}
 	out.WriteString(" = ")
 	if v.Value != nil {
 		out.WriteString(v.Value.String())
@@ -162,7 +252,15 @@ type IfExpression struct {
 	Token       tokens.Token // The 'if' token
 	Condition   Expression
 	Consequence *BlockStatement
+	// Alternative is the else branch. For `else if` it is a block holding
+	// only the nested if expression, with the nested 'if' token as its Token.
 	Alternative *BlockStatement
+	Else        tokens.Token // The 'else' token, if there is an alternative
+}
+
+// IsElseIf reports whether the else branch was written as `else if`.
+func (ie *IfExpression) IsElseIf() bool {
+	return ie.Alternative != nil && ie.Alternative.Token.TokenType == tokens.If
 }
 
 func (ie *IfExpression) expressionNode()    {}
@@ -173,16 +271,48 @@ func (ie *IfExpression) String() string {
 	out.WriteString(ie.Condition.String())
 	out.WriteString(" ")
 	out.WriteString(ie.Consequence.String())
-	if ie.Alternative != nil {
+	if ie.IsElseIf() {
+		out.WriteString("else ")
+		out.WriteString(ie.Alternative.Statements[0].String())
+	} else if ie.Alternative != nil {
 		out.WriteString("else ")
 		out.WriteString(ie.Alternative.String())
 	}
 	return out.String()
 }
 
+// MatchExpression compares a value against the pattern of each arm in turn
+// and evaluates the body of the first arm that matches.
+type MatchExpression struct {
+	Token tokens.Token // The 'match' token
+	Value Expression
+	Arms  []*MatchArm
+	End   tokens.Token // the } token
+}
+
+// MatchArm is `pattern => body`. Patterns are literals, names to bind, _ and
+// array and hash literals of patterns. The body is an *ExpressionStatement or
+// a *BlockStatement.
+type MatchArm struct {
+	Pattern Expression
+	Body    Statement
+}
+
+func (me *MatchExpression) expressionNode()    {}
+func (me *MatchExpression) TokenValue() string { return me.Token.Value }
+func (me *MatchExpression) String() string {
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.Pattern.String()+" => "+arm.Body.String())
+	}
+
+	return "match" + me.Value.String() + " {" + strings.Join(arms, ", ") + "}"
+}
+
 type BlockStatement struct {
 	Token      tokens.Token // the { token
 	Statements []Statement
+	End        tokens.Token // the } token
 }
 
 func (bs *BlockStatement) statementNode()     {}
@@ -212,28 +342,75 @@ func (ie *WhileLiteral) String() string {
 	return out.String()
 }
 
+// ForLiteral runs its body for every value of an iterable, binding each
+// value to a name or pattern: `for ([key, value] in hash) { }`.
+type ForLiteral struct {
+	Token    tokens.Token // the 'for' token
+	Pattern  Expression
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fl *ForLiteral) expressionNode()    {}
+func (fl *ForLiteral) TokenValue() string { return fl.Token.Value }
+func (fl *ForLiteral) String() string {
+	return "for(" + fl.Pattern.String() + " in " + fl.Iterable.String() + ") " + fl.Body.String()
+}
+
 type FunctionLiteral struct {
 	Token      tokens.Token // The 'fn' token
+	Name       *Identifier  // nil for anonymous functions
 	Parameters []*Identifier
-	Body       *BlockStatement
+	// Patterns destructures parameters, as in `func([a, b]) {}`. It is nil
+	// unless a parameter is destructured; such a parameter has an empty name.
+	Patterns []Expression
+	Defaults []Expression // the default of each parameter, nil when it has none
+	Rest     *Identifier  // collects the remaining arguments, nil when absent
+	Body     *BlockStatement
+	// Generator is set when the body yields, outside of nested functions.
+	// Calling a generator returns an iterator over the values it yields.
+	Generator bool
 }
 
 func (fl *FunctionLiteral) expressionNode()    {}
 func (fl *FunctionLiteral) TokenValue() string { return fl.Token.Value }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
-	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
-	}
 	out.WriteString(fl.TokenValue())
+	if fl.Name != nil {
+		out.WriteString(" " + fl.Name.String())
+	}
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(ParameterList(fl.Parameters, fl.Patterns, fl.Defaults, fl.Rest))
 	out.WriteString(") ")
 	out.WriteString(fl.Body.String())
 	return out.String()
 }
 
+// ParameterList renders parameters as they are written in a function
+// literal, e.g. `a, b = 2, ...rest`.
+func ParameterList(parameters []*Identifier, patterns []Expression, defaults []Expression, rest *Identifier) string {
+	params := []string{}
+	for i, p := range parameters {
+		param := p.String()
+		if i < len(patterns) && patterns[i] != nil {
+			param = patterns[i].String()
+		}
+
+		if i < len(defaults) && defaults[i] != nil {
+			param += " = " + defaults[i].String()
+		}
+
+		params = append(params, param)
+	}
+
+	if rest != nil {
+		params = append(params, "..."+rest.String())
+	}
+
+	return strings.Join(params, ", ")
+}
+
 type CallExpression struct {
 	Token     tokens.Token // The '(' token
 	Function  Expression   // Identifier or FunctionLiteral
@@ -255,6 +432,17 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+// SpreadExpression passes the elements of an array as separate arguments,
+// as in `f(...args)`.
+type SpreadExpression struct {
+	Token tokens.Token // the ... token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()    {}
+func (se *SpreadExpression) TokenValue() string { return se.Token.Value }
+func (se *SpreadExpression) String() string     { return "..." + se.Value.String() }
+
 type StringLiteral struct {
 	Token tokens.Token
 	Value string
@@ -264,6 +452,36 @@ func (s *StringLiteral) expressionNode()    {}
 func (s *StringLiteral) TokenValue() string { return s.Token.Value }
 func (s *StringLiteral) String() string     { return s.Token.Value }
 
+// TemplateLiteral is a string with embedded ${...} expressions. Its literal
+// pieces are StringLiterals.
+type TemplateLiteral struct {
+	Token tokens.Token
+	Parts []Expression
+}
+
+// IsLiteral reports whether part is a piece of the template's text rather
+// than an embedded expression, which may be a string literal as well.
+func (t *TemplateLiteral) IsLiteral(part Expression) bool {
+	str, ok := part.(*StringLiteral)
+	return ok && str.Token.TokenType == tokens.Template
+}
+
+func (t *TemplateLiteral) expressionNode()    {}
+func (t *TemplateLiteral) TokenValue() string { return t.Token.Value }
+func (t *TemplateLiteral) String() string {
+	var out bytes.Buffer
+	out.WriteString("\"")
+	for _, part := range t.Parts {
+		if t.IsLiteral(part) {
+			out.WriteString(part.(*StringLiteral).Value)
+		} else {
+			out.WriteString("${" + part.String() + "}")
+		}
+	}
+	out.WriteString("\"")
+	return out.String()
+}
+
 type ArrayLiteral struct {
 	Token    tokens.Token
 	Elements []Expression
@@ -305,9 +523,48 @@ func (idx *IndexExpression) String() string {
 	return out.String()
 }
 
+// MemberExpression reads a field or method of an instance, `point.x`.
+type MemberExpression struct {
+	Token  tokens.Token // the '.' token
+	Object Expression
+	Member *Identifier
+}
+
+func (me *MemberExpression) expressionNode()    {}
+func (me *MemberExpression) TokenValue() string { return me.Token.Value }
+func (me *MemberExpression) String() string {
+	return me.Object.String() + "." + me.Member.String()
+}
+
+type SetLiteral struct {
+	Token    tokens.Token
+	Elements []Expression
+}
+
+func (set *SetLiteral) expressionNode()    {}
+func (set *SetLiteral) TokenValue() string { return set.Token.Value }
+func (set *SetLiteral) String() string {
+	elements := []string{}
+
+	for _, element := range set.Elements {
+		elements = append(elements, element.String())
+	}
+
+	return "#{" + strings.Join(elements, ", ") + "}"
+}
+
 type HashLiteral struct {
 	Token tokens.Token
 	Pairs map[Expression]Expression
+	// Keys holds the keys of Pairs in source order.
+	Keys []Expression
+}
+
+// IsShorthand reports whether the pair for key was written as just a name:
+// {name} is short for {"name": name}.
+func (hash *HashLiteral) IsShorthand(key Expression) bool {
+	str, ok := key.(*StringLiteral)
+	return ok && str.Token.TokenType == tokens.Identifier
 }
 
 func (hash *HashLiteral) expressionNode()    {}
@@ -315,8 +572,8 @@ func (hash *HashLiteral) TokenValue() string { return hash.Token.Value }
 func (hash *HashLiteral) String() string {
 	var out bytes.Buffer
 	pairs := []string{}
-	for key, value := range hash.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hash.Keys {
+		pairs = append(pairs, key.String()+":"+hash.Pairs[key].String())
 	}
 
 	out.WriteString("{")
@@ -0,0 +1,74 @@
+package ast
+
+import (
+	"fmt"
+	"github.com/kanersps/loop/parser/tokens"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Fprint writes node and its children to w as an indented tree, one field per
+// line. Tokens are left out since every node already shows its own values.
+func Fprint(w io.Writer, node Node) {
+	printValue(w, "", reflect.ValueOf(node), 0)
+}
+
+var tokenType = reflect.TypeOf(tokens.Token{})
+
+func (p *Program) PrintAST(w io.Writer) {
+	Fprint(w, p)
+}
+
+func printValue(w io.Writer, label string, v reflect.Value, depth int) {
+	prefix := strings.Repeat("  ", depth)
+	if label != "" {
+		prefix += label + ": "
+	}
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		fmt.Fprintf(w, "%snil\n", prefix)
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			fmt.Fprintf(w, "%snil\n", prefix)
+			return
+		}
+
+		if v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Struct {
+			printStruct(w, prefix, v.Elem(), depth)
+			return
+		}
+
+		printValue(w, label, v.Elem(), depth)
+	case reflect.Struct:
+		printStruct(w, prefix, v, depth)
+	case reflect.Slice:
+		fmt.Fprintf(w, "%s(%d)\n", prefix, v.Len())
+		for idx := 0; idx < v.Len(); idx++ {
+			printValue(w, fmt.Sprint(idx), v.Index(idx), depth+1)
+		}
+	case reflect.Map:
+		fmt.Fprintf(w, "%s(%d)\n", prefix, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			printValue(w, "key", iter.Key(), depth+1)
+			printValue(w, "value", iter.Value(), depth+1)
+		}
+	default:
+		fmt.Fprintf(w, "%s%#v\n", prefix, v.Interface())
+	}
+}
+
+func printStruct(w io.Writer, prefix string, v reflect.Value, depth int) {
+	fmt.Fprintf(w, "%s%s\n", prefix, v.Type().Name())
+
+	for idx := 0; idx < v.NumField(); idx++ {
+		field := v.Type().Field(idx)
+		if field.PkgPath != "" || field.Type == tokenType {
+			continue
+		}
+
+		printValue(w, field.Name, v.Field(idx), depth+1)
+	}
+}
@@ -0,0 +1,182 @@
+package ast
+
+import (
+	"github.com/kanersps/loop/parser/tokens"
+	"reflect"
+)
+
+// Inspect traverses the tree rooted at node in source order, calling f for
+// every node. Children are skipped when f returns false. Missing children,
+// which a parse error can leave behind, are not visited.
+func Inspect(node Node, f func(Node) bool) {
+	if isNil(node) || !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, statement := range n.Statements {
+			Inspect(statement, f)
+		}
+	case *ExpressionStatement:
+		Inspect(n.Expression, f)
+	case *VariableStatement:
+		Inspect(n.Name, f)
+		Inspect(n.Pattern, f)
+		Inspect(n.Value, f)
+	case *FunctionStatement:
+		Inspect(n.Function, f)
+	case *StructStatement:
+		Inspect(n.Name, f)
+		Inspect(n.Body, f)
+	case *AssignStatement:
+		Inspect(n.Target, f)
+		Inspect(n.Value, f)
+	case *ReturnStatement:
+		Inspect(n.ReturnValue, f)
+	case *YieldStatement:
+		Inspect(n.Value, f)
+	case *BlockStatement:
+		for _, statement := range n.Statements {
+			Inspect(statement, f)
+		}
+	case *PrefixExpression:
+		Inspect(n.Right, f)
+	case *InfixExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *IfExpression:
+		Inspect(n.Condition, f)
+		Inspect(n.Consequence, f)
+		Inspect(n.Alternative, f)
+	case *MatchExpression:
+		Inspect(n.Value, f)
+		for _, arm := range n.Arms {
+			Inspect(arm.Pattern, f)
+			Inspect(arm.Body, f)
+		}
+	case *WhileLiteral:
+		Inspect(n.Condition, f)
+		Inspect(n.Body, f)
+	case *ForLiteral:
+		Inspect(n.Pattern, f)
+		Inspect(n.Iterable, f)
+		Inspect(n.Body, f)
+	case *FunctionLiteral:
+		Inspect(n.Name, f)
+		for i, parameter := range n.Parameters {
+			Inspect(parameter, f)
+			if i < len(n.Patterns) {
+				Inspect(n.Patterns[i], f)
+			}
+			if i < len(n.Defaults) {
+				Inspect(n.Defaults[i], f)
+			}
+		}
+		Inspect(n.Rest, f)
+		Inspect(n.Body, f)
+	case *SpreadExpression:
+		Inspect(n.Value, f)
+	case *CallExpression:
+		Inspect(n.Function, f)
+		for _, argument := range n.Arguments {
+			Inspect(argument, f)
+		}
+	case *TemplateLiteral:
+		for _, part := range n.Parts {
+			Inspect(part, f)
+		}
+	case *ArrayLiteral:
+		for _, element := range n.Elements {
+			Inspect(element, f)
+		}
+	case *SetLiteral:
+		for _, element := range n.Elements {
+			Inspect(element, f)
+		}
+	case *IndexExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Index, f)
+	case *MemberExpression:
+		Inspect(n.Object, f)
+		Inspect(n.Member, f)
+	case *HashLiteral:
+		for _, key := range n.Keys {
+			Inspect(key, f)
+			Inspect(n.Pairs[key], f)
+		}
+	}
+}
+
+func isNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+
+	v := reflect.ValueOf(node)
+
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// FirstToken returns the leftmost token of a node, which is where the node
+// starts in the source.
+func FirstToken(node Node) tokens.Token {
+	switch node := node.(type) {
+	case *VariableStatement:
+		return node.Token
+	case *FunctionStatement:
+		return node.Token
+	case *StructStatement:
+		return node.Token
+	case *AssignStatement:
+		return FirstToken(node.Target)
+	case *ReturnStatement:
+		return node.Token
+	case *YieldStatement:
+		return node.Token
+	case *ExpressionStatement:
+		return node.Token
+	case *BlockStatement:
+		return node.Token
+	case *InfixExpression:
+		return FirstToken(node.Left)
+	case *CallExpression:
+		return FirstToken(node.Function)
+	case *IndexExpression:
+		return FirstToken(node.Left)
+	case *MemberExpression:
+		return FirstToken(node.Object)
+	case *Identifier:
+		return node.Token
+	case *IntegerLiteral:
+		return node.Token
+	case *Boolean:
+		return node.Token
+	case *StringLiteral:
+		return node.Token
+	case *TemplateLiteral:
+		return node.Token
+	case *PrefixExpression:
+		return node.Token
+	case *IfExpression:
+		return node.Token
+	case *MatchExpression:
+		return node.Token
+	case *WhileLiteral:
+		return node.Token
+	case *ForLiteral:
+		return node.Token
+	case *FunctionLiteral:
+		return node.Token
+	case *SpreadExpression:
+		return node.Token
+	case *ArrayLiteral:
+		return node.Token
+	case *HashLiteral:
+		return node.Token
+	case *SetLiteral:
+		return node.Token
+	}
+
+	return tokens.Token{}
+}
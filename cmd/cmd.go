@@ -9,33 +9,68 @@ import (
 	"github.com/kanersps/loop/parser/lexer"
 	"github.com/kanersps/loop/repl"
 	"io"
-	"io/ioutil"
 	"log"
 	"os"
 )
 
+var subcommands = map[string]func(args []string) int{
+	"fmt":  runFmt,
+	"lsp":  runLsp,
+	"test": runTest,
+	"vet":  runVet,
+}
+
 func Execute() {
-	executeFile := flag.String("file", "none-provided", "The file you want to interpret")
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
+	}
+
+	executeFile := flag.String("file", "none-provided", "The file you want to interpret, or - to read it from stdin")
+
+	strict := flag.Bool("strict", false, "Only accept booleans as conditions in if, while, !, && and ||")
+
+	allowShadowing := flag.Bool("allow-shadowing", false, "Allow declarations named after builtin functions")
 
 	flag.Parse()
 
+	// Allows running `loop script.loop`, which is what a #! line expands to.
+	if *executeFile == "none-provided" && flag.NArg() > 0 {
+		*executeFile = flag.Arg(0)
+	}
+
 	if *executeFile == "none-provided" {
 		repl.Console(os.Stdin, os.Stdout)
 	} else {
 		fmt.Println(*executeFile)
 
 		env := object.NewEnvironment()
+		env.Strict = *strict
+		env.AllowShadowing = *allowShadowing
+
+		// A file name of "-" reads the program from stdin.
+		input := os.Stdin
 
-		input, err := ioutil.ReadFile(*executeFile)
+		if *executeFile != "-" {
+			file, err := os.Open(*executeFile)
 
-		if err != nil {
-			log.Fatal(err)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			defer file.Close()
+			input = file
 		}
 
-		l := lexer.Create(string(input))
+		l := lexer.CreateFromReader(input)
 		p := parser.Create(l)
 		program := p.ParseProgram()
 
+		if l.Err() != nil {
+			log.Fatal(l.Err())
+		}
+
 		if len(p.Errors()) != 0 {
 			printParserErrors(os.Stdout, p.Errors())
 			log.Fatal()
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/kanersps/loop/format"
+	"io/ioutil"
+	"os"
+)
+
+// runFmt prints the canonical form of each file, or rewrites the files in
+// place when -w is given.
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the result to the source file instead of stdout")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: loop fmt [-w] files...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	status := 0
+
+	for _, path := range flags.Args() {
+		input, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+
+		formatted, err := format.Source(input)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s:\n", path)
+			printParserErrors(os.Stderr, []string{err.Error()})
+			status = 1
+			continue
+		}
+
+		if !*write {
+			os.Stdout.Write(formatted)
+			continue
+		}
+
+		if bytes.Equal(input, formatted) {
+			continue
+		}
+
+		if err := ioutil.WriteFile(path, formatted, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+		}
+	}
+
+	return status
+}
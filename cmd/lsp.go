@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/kanersps/loop/lsp"
+	"os"
+)
+
+// runLsp serves the language server protocol over stdin and stdout.
+func runLsp(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "usage: loop lsp")
+		return 2
+	}
+
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
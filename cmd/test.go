@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"github.com/kanersps/loop/tester"
+	"os"
+	"time"
+)
+
+// runTest runs the tests in the *_test.loop files below the given paths and
+// fails when any of them fail.
+func runTest(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	verbose := flags.Bool("v", false, "also list the tests that passed")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: loop test [-v] [paths...]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, err := tester.Discover(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "no test files found")
+		return 1
+	}
+
+	status := 0
+
+	for _, file := range files {
+		start := time.Now()
+
+		results, err := tester.RunFile(file, os.Stdout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Printf("FAIL\t%s\n", file)
+			status = 1
+			continue
+		}
+
+		if tester.Report(os.Stdout, results, *verbose) {
+			fmt.Printf("ok\t%s\t%.3fs\n", file, time.Since(start).Seconds())
+		} else {
+			fmt.Printf("FAIL\t%s\t%.3fs\n", file, time.Since(start).Seconds())
+			status = 1
+		}
+	}
+
+	return status
+}
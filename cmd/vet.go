@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"github.com/kanersps/loop/analysis"
+	"github.com/kanersps/loop/parser"
+	"github.com/kanersps/loop/parser/lexer"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runVet reports suspicious code in the given files, or in every .loop file
+// below the given directories.
+func runVet(args []string) int {
+	flags := flag.NewFlagSet("vet", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: loop vet paths...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	status := 0
+
+	for _, path := range flags.Args() {
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() || (file != path && !strings.HasSuffix(file, ".loop")) {
+				return nil
+			}
+
+			if !vetFile(file) {
+				status = 1
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+		}
+	}
+
+	return status
+}
+
+// vetFile prints the problems found in a file and reports whether there
+// were none.
+func vetFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	defer file.Close()
+
+	l := lexer.CreateFromReader(file)
+	p := parser.Create(l)
+	program := p.ParseProgram()
+
+	if l.Err() != nil {
+		fmt.Fprintln(os.Stderr, l.Err())
+		return false
+	}
+
+	for _, err := range p.Diagnostics() {
+		fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", path, err.Line, err.Column, err.Message)
+	}
+
+	if len(p.Diagnostics()) != 0 {
+		return false
+	}
+
+	diagnostics := analysis.Vet(program)
+
+	for _, diagnostic := range diagnostics {
+		fmt.Fprintf(os.Stderr, "%s:%s\n", path, diagnostic)
+	}
+
+	return len(diagnostics) == 0
+}
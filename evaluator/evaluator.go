@@ -9,3 +9,7 @@ import (
 func Eval(node ast.Node, env *models.Environment) models.Object {
 	return helpers.Eval(node, env)
 }
+
+func ApplyFunction(fn models.Object, args []models.Object, env *models.Environment) models.Object {
+	return helpers.ApplyFunction(fn, args, env)
+}
@@ -1,10 +1,12 @@
 package evaluator
 
 import (
+	"bytes"
 	"github.com/kanersps/loop/models"
 	"github.com/kanersps/loop/object"
 	"github.com/kanersps/loop/parser"
 	"github.com/kanersps/loop/parser/lexer"
+	"strings"
 	"testing"
 )
 
@@ -43,6 +45,29 @@ func TestEval_BooleanExpression(t *testing.T) {
 		{"3 != 2", true},
 		{"(1 + 1) == 2", true},
 		{"true == true", true},
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+		{`"a" == "b"`, false},
+		{`"1" == 1`, false},
+		{"[1, [2, 3]] == [1, [2, 3]]", true},
+		{"[1, 2] == [1, 2, 3]", false},
+		{"[1, 2] != [2, 1]", true},
+		{`{"a": [1], "b": 2} == {"b": 2, "a": [1]}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"b": 1}`, false},
+		{"var a = [1]; a is a", true},
+		{"[1] is [1]", false},
+		{"true is true", true},
+		{"var f = func() {}; f == f", true},
+		{"func() {} == func() {}", false},
+		{`"apple" < "banana"`, true},
+		{`"b" > "abc"`, true},
+		{`"ab" < "abc"`, true},
+		{`"a" < "a"`, false},
+		{"[1, 2] < [1, 3]", true},
+		{"[1, 2] < [1, 2, 0]", true},
+		{"[2] > [1, 9]", true},
+		{`["b"] > ["a", "z"]`, true},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -50,6 +75,25 @@ func TestEval_BooleanExpression(t *testing.T) {
 	}
 }
 
+func TestEval_ComparisonErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a" < 1`, "TYPE-MISMATCH: STRING < INTEGER"},
+		{`{} < {}`, "UNKNOWN-OPERATOR: HASH < HASH"},
+		{`[1] < ["a"]`, "UNKNOWN-OPERATOR: ARRAY < ARRAY"},
+		{`"a" - "b"`, "UNKNOWN-OPERATOR: STRING - STRING"},
+	}
+
+	for _, tc := range tests {
+		err, ok := testEval(tc.input).(*models.Error)
+		if !ok || err.Message != tc.expected {
+			t.Errorf("wrong error for %s. expected=%q. got=%+v", tc.input, tc.expected, err)
+		}
+	}
+}
+
 func TestEval_BangOperator(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -61,6 +105,13 @@ func TestEval_BangOperator(t *testing.T) {
 		{"!!true", true},
 		{"!!false", false},
 		{"!!5", true},
+		{"!0", true},
+		{"!\"\"", true},
+		{"!\"a\"", false},
+		{"![]", true},
+		{"![0]", false},
+		{"!{}", true},
+		{"!len", false},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -75,11 +126,15 @@ func TestEval_ConditionalExpressions(t *testing.T) {
 	}{
 		{"if (true) { return 10; }", 10},
 		{"if (true) { 10; }", 10},
-		{"if (1) { return 10; }", nil},
+		{"if (1) { return 10; }", 10},
+		{"if (0) { return 10; }", nil},
 		{"if (false) { return 10; }", nil},
 		{"if (1 == 1) { return 10; }", 10},
 		{"if (false) { return 10; } else { return 20; }", 20},
 		{"if (true) { return 30; } else { return 20; }", 30},
+		{"if (false) { 10 } else if (true) { 20 } else { 30 }", 20},
+		{"if (false) { 10 } else if (false) { 20 } else { 30 }", 30},
+		{"if (false) { 10 } else if (false) { 20 }", nil},
 	}
 
 	for _, tc := range tests {
@@ -93,6 +148,69 @@ func TestEval_ConditionalExpressions(t *testing.T) {
 	}
 }
 
+func TestEval_LogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && \"a\"", true},
+		{"[] || 0", false},
+		{"false && missing", false},
+		{"true || missing", true},
+		{"1 == 1 && 2 > 1", true},
+		{"false && false || true", true},
+		{"var i = 0; while (i < 3 && true) { var i = i + 1 }; i == 3", true},
+		{"var arr = [1, 2]; var n = 0; while (len(arr)) { var arr = []; var n = n + 1 }; n == 1", true},
+	}
+
+	for _, tc := range tests {
+		testBooleanObject(t, testEval(tc.input), tc.expected)
+	}
+
+	err, ok := testEval("true && missing").(*models.Error)
+	if !ok || err.Message != "UNKNOWN-IDENTIFIER: missing" {
+		t.Errorf("errors in the right operand should propagate. got=%+v", err)
+	}
+}
+
+func TestEval_StrictConditions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // empty when no error is expected
+	}{
+		{"if (1) { 2 }", "NON-BOOLEAN CONDITION: INTEGER"},
+		{"if (true) { 2 }", ""},
+		{"while (\"\") { 2 }", "NON-BOOLEAN CONDITION: STRING"},
+		{"!0", "NON-BOOLEAN CONDITION: INTEGER"},
+		{"true && []", "NON-BOOLEAN CONDITION: ARRAY"},
+		{"false || 1 == 1", ""},
+		{"var f = func() { if (0) { 1 } }; f()", "NON-BOOLEAN CONDITION: INTEGER"},
+	}
+
+	for _, tc := range tests {
+		env := object.NewEnvironment()
+		env.Strict = true
+
+		evaluated := Eval(parser.Create(lexer.Create(tc.input)).ParseProgram(), env)
+		err, isErr := evaluated.(*models.Error)
+
+		if tc.expected == "" {
+			if isErr {
+				t.Errorf("unexpected error for %s: %s", tc.input, err.Message)
+			}
+			continue
+		}
+
+		if !isErr || err.Message != tc.expected {
+			t.Errorf("wrong result for %s. expected=%q. got=%+v", tc.input, tc.expected, evaluated)
+		}
+	}
+}
+
 func TestEval_ReturnStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -138,6 +256,7 @@ func TestEval_ErrorHandling(t *testing.T) {
 		{"test", "UNKNOWN-IDENTIFIER: test"},
 		{`"Test" - "Test"`, "UNKNOWN-OPERATOR: STRING - STRING"},
 		{`while(true - 2) { return ""; }`, "TYPE-MISMATCH: BOOLEAN - INTEGER"},
+		{"1 / 0", "DIVISION BY ZERO"},
 	}
 
 	for _, tc := range tests {
@@ -210,6 +329,114 @@ func TestEval_FunctionExecution(t *testing.T) {
 	}
 }
 
+func TestEval_FunctionArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"var f = func(a, b) { a + b }; f(1, 2)", 3},
+		{"var f = func(a, b) { a + b }; f(1)", "WRONG NUMBER OF ARGUMENTS TO FUNCTION `f`. expected=2. got=1"},
+		{"func(a) { a }(1, 2)", "WRONG NUMBER OF ARGUMENTS TO FUNCTION. expected=1. got=2"},
+		{"var f = func(a, b = 2) { a + b }; f(1)", 3},
+		{"var f = func(a, b = 2) { a + b }; f(1, 5)", 6},
+		{"var f = func(a, b = a * 10) { b }; f(4)", 40},
+		{"var f = func(a, b = 2) { a + b }; f()", "WRONG NUMBER OF ARGUMENTS TO FUNCTION `f`. expected=1 to 2. got=0"},
+		{"var f = func(a, b = missing) { a }; f(1)", "UNKNOWN-IDENTIFIER: missing"},
+		{"var f = func(first, ...rest) { len(rest) }; f(1, 2, 3)", 2},
+		{"var f = func(first, ...rest) { len(rest) }; f(1)", 0},
+		{"var f = func(first, ...rest) { rest[1] }; f(1, 2, 3)", 3},
+		{"var f = func(first, ...rest) { first }; f()", "WRONG NUMBER OF ARGUMENTS TO FUNCTION `f`. expected=at least 1. got=0"},
+		{"var f = func(a, b, c) { a + b * c }; f(...[1, 2, 3])", 7},
+		{"var f = func(a, b, c) { a + b * c }; f(1, ...[2], 3)", 7},
+		{"var f = func(...xs) { len(xs) }; f(...[], ...[1, 2])", 2},
+		{"len(...[\"abc\"])", 3},
+		{"var f = func(a) { a }; f(...1)", "CANNOT SPREAD INTEGER"},
+	}
+
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+
+		switch expected := tc.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			err, ok := evaluated.(*models.Error)
+			if !ok {
+				t.Errorf("object is not models.Error for %s. got=%T (%+v)", tc.input, evaluated, evaluated)
+				continue
+			}
+
+			if err.Message != expected {
+				t.Errorf("Wrong error received. expected=%q. got=%q", expected, err.Message)
+			}
+		}
+	}
+}
+
+func TestEval_FunctionDeclarations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"func add(a, b) { a + b }; add(1, 2)", 3},
+		{"var r = add(1, 2); func add(a, b) { a + b }; r", 3},
+		{"func even(n) { if (n == 0) { true } else { odd(n - 1) } }; func odd(n) { if (n == 0) { false } else { even(n - 1) } }; even(10)", true},
+		{"func fact(n) { if (n < 2) { return 1 }; n * fact(n - 1) }; fact(5)", 120},
+		{"var f = func() { return inner(); func inner() { 7 } }; f()", 7},
+		{"func f(a) { a }; f()", "WRONG NUMBER OF ARGUMENTS TO FUNCTION `f`. expected=1. got=0"},
+		{"var f = func fact(n) { if (n < 2) { return 1 }; n * fact(n - 1) }; f(5)", 120},
+		{"var f = func fact(n) { n }; fact(5)", "UNKNOWN-IDENTIFIER: fact"},
+		{"var fact = 0; var f = func fact(n) { if (n < 2) { return 1 }; n * fact(n - 1) }; f(4) + fact", 24},
+		{"var x = 1; var f = func g() { var x = x + 1; x }; f(); x", 2},
+		{"var f = func g(g) { g }; f(3)", 3},
+	}
+
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+
+		switch expected := tc.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			err, ok := evaluated.(*models.Error)
+			if !ok || err.Message != expected {
+				t.Errorf("wrong error for %s. expected=%q. got=%+v", tc.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestEval_FunctionNames(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"func add(a, b) { a + b }; add", "add"},
+		{"var sub = func(a, b) { a - b }; sub", "sub"},
+		{"var g = func h() { 1 }; g", "h"},
+		{"func(a) { a }", ""},
+	}
+
+	for _, tc := range tests {
+		fn, ok := testEval(tc.input).(*models.Function)
+		if !ok {
+			t.Errorf("%s did not evaluate to a function", tc.input)
+			continue
+		}
+
+		if fn.Name != tc.expected {
+			t.Errorf("wrong name for %s. expected=%q. got=%q", tc.input, tc.expected, fn.Name)
+		}
+	}
+
+	fn := testEval("func add(a, b = 1) { a + b }; add").(*models.Function)
+	if !strings.HasPrefix(fn.Inspect(), "func add(a, b = 1) {") {
+		t.Errorf("wrong inspect output. got=%q", fn.Inspect())
+	}
+}
+
 func TestEval_Strings(t *testing.T) {
 	input := `"Testing two"`
 
@@ -225,6 +452,37 @@ func TestEval_Strings(t *testing.T) {
 	}
 }
 
+func TestEval_StringInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`var name = "Loop"; "Hello ${name}!"`, "Hello Loop!"},
+		{`var a = 2; "${a} * 3 = ${a * 3}"`, "2 * 3 = 6"},
+		{`"${[1, true]} ${"nested ${1 + 1}"}"`, "[1, true] nested 2"},
+		{"\"tab\\tquote\\\"\" + `raw\\n`", "tab\tquote\"raw\\n"},
+	}
+
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+		str, ok := evaluated.(*models.String)
+
+		if !ok {
+			t.Errorf("Object is not string. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if str.Value != tc.expected {
+			t.Errorf("String has incorrect value expected=%q. got=%q", tc.expected, str.Value)
+		}
+	}
+
+	errObj, ok := testEval(`"${missing}"`).(*models.Error)
+	if !ok || errObj.Message != "UNKNOWN-IDENTIFIER: missing" {
+		t.Errorf("interpolation errors should propagate. got=%+v", errObj)
+	}
+}
+
 func TestEval_StringConcatenation(t *testing.T) {
 	input := `"Testing" + " " + "two"`
 
@@ -282,8 +540,11 @@ func TestEval_BuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("test")`, 4},
 		{`len(1)`, "ARGUMENT INVALID TYPE TO BUILT-IN FUNCTION `len`. got=INTEGER. expected=STRING"},
+		{`len("héllo 世界")`, 8},
 		{`len("1", "2")`, "WRONG NUMBER OF ARGUMENTS TO BUILT-IN FUNCTION `len`. expected=1. got=2"},
 		{`len()`, "WRONG NUMBER OF ARGUMENTS TO BUILT-IN FUNCTION `len`. expected=1. got=0"},
+		{`var a = [1]; append(a, 2); len(a)`, 1},
+		{`freeze()`, "WRONG NUMBER OF ARGUMENTS TO BUILT-IN FUNCTION `freeze`. expected=1. got=0"},
 	}
 
 	for _, tc := range tests {
@@ -306,6 +567,100 @@ func TestEval_BuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestEval_Assertions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // empty when the assertion holds
+	}{
+		{`assert(1 == 1)`, ""},
+		{`assert(1 == 2)`, "ASSERTION FAILED: condition is false"},
+		{`assert(false, "custom")`, "ASSERTION FAILED: custom"},
+		{`assert_eq([1, "a", {"b": 2}], [1, "a", {"b": 2}])`, ""},
+		{`assert_eq(1 + 1, 3)`, "ASSERTION FAILED: expected=3. got=2"},
+		{`assert_eq("1", 1)`, "ASSERTION FAILED: expected=1. got=1"},
+		{`assert_error(func() { 1 + true })`, ""},
+		{`assert_error(func() { 1 + true }, "TYPE-MISMATCH")`, ""},
+		{`assert_error(func() { 1 + true }, "UNKNOWN")`, "ASSERTION FAILED: expected an error containing \"UNKNOWN\". got=\"TYPE-MISMATCH: INTEGER + BOOLEAN\""},
+		{`assert_error(func() { 1 })`, "ASSERTION FAILED: expected an error. got=1"},
+		{`assert_error(func() {})`, "ASSERTION FAILED: expected an error. got=null"},
+		{`assert_error(func() { var x = 1 })`, "ASSERTION FAILED: expected an error. got=null"},
+		{`var i = 0; while (i < 5) { assert(i < 2); var i = i + 1 }`, "ASSERTION FAILED: condition is false"},
+	}
+
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+
+		if tc.expected == "" {
+			testNullObject(t, evaluated)
+			continue
+		}
+
+		err, ok := evaluated.(*models.Error)
+		if !ok {
+			t.Errorf("object is not models.Error for %s. got=%T (%+v)", tc.input, evaluated, evaluated)
+			continue
+		}
+
+		if err.Message != tc.expected {
+			t.Errorf("Wrong error received. expected=%q. got=%q", tc.expected, err.Message)
+		}
+	}
+}
+
+func TestEval_ErrorPositions(t *testing.T) {
+	tests := []struct {
+		input  string
+		line   int
+		column int
+	}{
+		{"1 + true", 1, 3},
+		{"var a = 1;\n  missing", 2, 3},
+		{"var f = func() {\n    -true\n}\nf()", 2, 5},
+		{"\n\nassert(false)", 3, 1},
+	}
+
+	for _, tc := range tests {
+		err, ok := testEval(tc.input).(*models.Error)
+		if !ok {
+			t.Errorf("no error returned for %q", tc.input)
+			continue
+		}
+
+		if err.Line != tc.line || err.Column != tc.column {
+			t.Errorf("wrong position for %q. expected=%d:%d. got=%d:%d", tc.input, tc.line, tc.column, err.Line, err.Column)
+		}
+	}
+}
+
+func TestEval_Streams(t *testing.T) {
+	input := `
+	var name = input("name: ")
+	println("hello " + name)
+	print(readline(), "!")
+	eprintln("warning")
+	readline()
+`
+
+	var out, errOut bytes.Buffer
+
+	l := lexer.Create(input)
+	p := parser.Create(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+	env.Streams = models.NewStreams(strings.NewReader("loop\r\nsecond"), &out, &errOut)
+
+	evaluated := Eval(program, env)
+	testNullObject(t, evaluated)
+
+	if out.String() != "name: hello loop\nsecond!" {
+		t.Errorf("wrong output written. got=%q", out.String())
+	}
+
+	if errOut.String() != "warning\n" {
+		t.Errorf("wrong error output written. got=%q", errOut.String())
+	}
+}
+
 func TestEval_Arrays(t *testing.T) {
 	input := `[1, 2 + 2, "three"]`
 
@@ -335,6 +690,8 @@ func TestEval_IndexExpressions(t *testing.T) {
 		{`[20, 1, 30][0]`, 20},
 		{`[3 * 2, 1, 30][0]`, 6},
 		{`var test = 0; [1, 2, 3][test]`, 1},
+		{`[1, 2, 3][3]`, nil},
+		{`[1, 2, 3][-1]`, nil},
 	}
 
 	for _, tc := range tests {
@@ -349,6 +706,34 @@ func TestEval_IndexExpressions(t *testing.T) {
 	}
 }
 
+func TestEval_StringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"héllo"[1]`, "é"},
+		{`"世界"[1]`, "界"},
+		{`var größe = "abc"; größe[0]`, "a"},
+		{`"abc"[3]`, nil},
+		{`"abc"[-1]`, nil},
+	}
+
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+		expected, ok := tc.expected.(string)
+
+		if !ok {
+			testNullObject(t, evaluated)
+			continue
+		}
+
+		str, ok := evaluated.(*models.String)
+		if !ok || str.Value != expected {
+			t.Errorf("wrong result for %s. expected=%q. got=%+v", tc.input, expected, evaluated)
+		}
+	}
+}
+
 func TestEval_Hashes(t *testing.T) {
 	input := `
 	var key = "hash_key"
@@ -383,6 +768,435 @@ func TestEval_Hashes(t *testing.T) {
 	}
 }
 
+func TestEval_Sets(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`#{1, 2, 2, "a"}`, "#{1, 2, a}"},
+		{`#{}`, "#{}"},
+		{`set([3, 1, 3])`, "#{3, 1}"},
+		{`set()`, "#{}"},
+		{`union(#{1, 2}, #{2, 3})`, "#{1, 2, 3}"},
+		{`intersection(#{1, 2, 3}, #{3, 2})`, "#{2, 3}"},
+		{`difference(#{1, 2, 3}, #{2})`, "#{1, 3}"},
+		{`2 in #{1, 2}`, "true"},
+		{`3 in #{1, 2}`, "false"},
+		{`[1] in #{1}`, "false"},
+		{`freeze([1, 2]) in #{freeze([1, 2])}`, "true"},
+		{`len(#{1, 1, 2})`, "2"},
+		{`[...#{1, 1, 2}, 3]`, "[1, 2, 3]"},
+		{`#{1, 2} == #{2, 1}`, "true"},
+		{`#{1, 2} == #{1, 3}`, "false"},
+		{`if (#{}) { 1 } else { 2 }`, "2"},
+		{`#{[1]}`, "Exception: SET MEMBER IS INCORRECT TYPE. got=ARRAY (at 1:1)"},
+		{`set([func() {}])`, "Exception: SET MEMBER IS INCORRECT TYPE. got=FUNCTION (at 1:1)"},
+		{`1 in 2`, "Exception: UNKNOWN-OPERATOR: INTEGER in INTEGER (at 1:3)"},
+		{`union(#{1}, [1])`, "Exception: ARGUMENT INVALID TYPE TO BUILT-IN FUNCTION `union` (argument 1). expected=SET. got=ARRAY (at 1:1)"},
+	}
+
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+		if evaluated == nil {
+			t.Errorf("%s evaluated to nil", tc.input)
+			continue
+		}
+
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("%s is wrong. expected=%q. got=%q", tc.input, tc.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestEval_InOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`2 in [1, 2, 3]`, true},
+		{`4 in [1, 2, 3]`, false},
+		{`[1] in [[1], [2]]`, true},
+		{`"a" in {"a": 1}`, true},
+		{`"b" in {"a": 1}`, false},
+		{`[1] in {"a": 1}`, false},
+		{`"ell" in "hello"`, true},
+		{`"eh" in "hello"`, false},
+		{`1 in #{1}`, true},
+	}
+
+	for _, tc := range tests {
+		testBooleanObject(t, testEval(tc.input), tc.expected)
+	}
+}
+
+func TestEval_Match(t *testing.T) {
+	describe := `var describe = func(x) {
+		match (x) {
+			0 => "zero",
+			-1 => "minus one",
+			"hi" => "greeting",
+			[] => "empty",
+			[a] => "one: ${a}",
+			[a, b] => "pair: ${a + b}",
+			[first, ...rest] => "many: ${first} and ${len(rest)} more",
+			{"type": "circle", "radius": r} => "circle of ${r}",
+			{"type": t} => {
+				var kind = "shape"
+				"${kind} ${t}"
+			},
+			_ => "other"
+		}
+	};`
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{describe + `describe(0)`, "zero"},
+		{describe + `describe(-1)`, "minus one"},
+		{describe + `describe("hi")`, "greeting"},
+		{describe + `describe([])`, "empty"},
+		{describe + `describe([5])`, "one: 5"},
+		{describe + `describe([1, 2])`, "pair: 3"},
+		{describe + `describe([1, 2, 3, 4])`, "many: 1 and 3 more"},
+		{describe + `describe({"type": "circle", "radius": 2})`, "circle of 2"},
+		{describe + `describe({"type": "square"})`, "shape square"},
+		{describe + `describe({"kind": "square"})`, "other"},
+		{describe + `describe(true)`, "other"},
+		{`match (1) { 2 => 2 }`, nil},
+		{`match ([1, [2, 3]]) { [a, [b, c]] => a + b + c }`, 6},
+		{`match ([1, 2]) { [a, ...rest] => len(rest) }`, 1},
+		{`match (5) { n => n * 2 }`, 10},
+		{`match (5) { _ => 1, n => n }`, 1},
+		{`var a = 1; match ([5]) { [a] => a }; a`, 1},
+		{`var a = 1; match ([5]) { [a] => a }`, 5},
+		{`const a = 1; match ([5]) { [a] => a }`, 5},
+		{`match ([5]) { [a] => a }; match (1) { 2 => a, _ => 0 }`, 0},
+	}
+
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+
+		switch expected := tc.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*models.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if str.Value != expected {
+				t.Errorf("wrong result. expected=%q. got=%q", expected, str.Value)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestEval_Destructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`var [a, b] = [1, 2]; a + b`, "3"},
+		{`var [a, b, ...rest] = [1, 2, 3, 4]; rest`, "[3, 4]"},
+		{`var [a, ...rest] = [1]; rest`, "[]"},
+		{`var [_, [b, c]] = [1, [2, 3]]; b + c`, "5"},
+		{`var {name, age} = {"name": "Ann", "age": 30}; "${name} ${age}"`, "Ann 30"},
+		{`var {"pos": [x, y]} = {"pos": [3, 4]}; x * y`, "12"},
+		{`var name = "Ann"; {name}`, "{name: Ann}"},
+		{`var f = func([a, b], {n} = {"n": 10}) { a + b + n }; f([1, 2])`, "13"},
+		{`var f = func({n}) { n }; f({"n": 1, "m": 2})`, "1"},
+		{`var [a, b] = [1, 2, 3]`, "Exception: WRONG NUMBER OF ELEMENTS TO DESTRUCTURE. expected=2. got=3 (at 1:5)"},
+		{`var [a, b, ...rest] = [1]`, "Exception: WRONG NUMBER OF ELEMENTS TO DESTRUCTURE. expected=at least 2. got=1 (at 1:5)"},
+		{`var [a] = {"a": 1}`, "Exception: CANNOT DESTRUCTURE HASH AS ARRAY (at 1:5)"},
+		{`var {a} = [1]`, "Exception: CANNOT DESTRUCTURE ARRAY AS HASH (at 1:5)"},
+		{`var {name, age} = {"name": "Ann"}`, "Exception: CANNOT DESTRUCTURE MISSING KEY age (at 1:12)"},
+		{`var f = func([a, b]) { a }; f([1])`, "Exception: WRONG NUMBER OF ELEMENTS TO DESTRUCTURE. expected=2. got=1 (at 1:14)"},
+	}
+
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+		if evaluated == nil {
+			t.Errorf("%s evaluated to nil", tc.input)
+			continue
+		}
+
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("%s is wrong. expected=%q. got=%q", tc.input, tc.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestEval_Constants(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`const x = 1; x`, "1"},
+		{`const x = 1; var x = 2`, "Exception: CANNOT REASSIGN CONSTANT x (at 1:18)"},
+		{`const x = 1; const x = 2`, "Exception: CANNOT REASSIGN CONSTANT x (at 1:20)"},
+		{`const [a, b] = [1, 2]; var [c, a] = [3, 4]`, "Exception: CANNOT REASSIGN CONSTANT a (at 1:32)"},
+		{`var i = 0; while (i < 3) { const y = i * 2; var i = i + 1 }; y`, "4"},
+		{`const x = 1; var f = func() { var x = 2; x }; f() + x`, "3"},
+		{`const x = 1; var f = func(x) { x }; f(5); x`, "1"},
+		{`var x = 1; const x = 2; x`, "2"},
+	}
+
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+		if evaluated == nil {
+			t.Errorf("%s evaluated to nil", tc.input)
+			continue
+		}
+
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("%s is wrong. expected=%q. got=%q", tc.input, tc.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestEval_Structs(t *testing.T) {
+	point := `struct Point {
+	var x = 0
+	var y = 0
+
+	func init(x, y) {
+		self.x = x
+		self.y = y
+	}
+
+	func sum() { self.x + self.y }
+
+	func move(dx) {
+		self.x = self.x + dx
+		self
+	}
+}
+`
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{point + `Point(1, 2)`, "Point{x: 1, y: 2}"},
+		{point + `Point(1, 2).sum()`, "3"},
+		{point + `var p = Point(1, 2); p.move(3); p.x`, "4"},
+		{point + `var sum = Point(1, 2).sum; sum()`, "3"},
+		{point + `Point(1)`, "Exception: WRONG NUMBER OF ARGUMENTS TO FUNCTION `Point.init`. expected=2. got=1 (at 17:1)"},
+		{point + `Point(1, 2) == Point(1, 2)`, "true"},
+		{point + `Point(1, 2) == Point(2, 1)`, "false"},
+		{point + `type_of(Point(1, 2))`, "Point"},
+		{point + `Point(1, 2).z`, "Exception: UNKNOWN MEMBER z OF Point (at 17:13)"},
+		{point + `var p = Point(1, 2); p.z = 1`, "Exception: UNKNOWN FIELD z OF Point (at 17:24)"},
+		{point + `var p = freeze(Point(1, 2)); p.x = 1`, "Exception: CANNOT ASSIGN TO FIELD x OF FROZEN Point (at 17:32)"},
+		{point + `var p = freeze(Point(1, 2)); p.move(1)`, "Exception: CANNOT ASSIGN TO FIELD x OF FROZEN Point (at 13:8)"},
+		{`struct Tags { var tags = [] }; var a = Tags(); var b = Tags(); a.tags == b.tags`, "true"},
+		{`struct Pair { var a = 1; var b = 2 }; Pair(5)`, "Pair{a: 5, b: 2}"},
+		{`struct Pair { var a = 1; var b = 2 }; Pair(1, 2, 3)`, "Exception: WRONG NUMBER OF ARGUMENTS TO STRUCT `Pair`. expected=0 to 2. got=3 (at 1:39)"},
+		{`struct Empty {}; Empty`, "struct Empty"},
+		{`var hits = 0; struct C { func hit() { var hits = hits + 1; hits } }; C().hit(); hits`, "1"},
+		{`struct Empty {}; type_of(1) + type_of("") + type_of(Empty)`, "INTEGERSTRINGSTRUCT"},
+		{`1.x`, "Exception: CANNOT ACCESS MEMBER x OF INTEGER (at 1:3)"},
+		{`struct len {}`, "Exception: CANNOT SHADOW BUILT-IN FUNCTION `len` (at 1:8)"},
+	}
+
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+		if evaluated == nil {
+			t.Errorf("%s evaluated to nil", tc.input)
+			continue
+		}
+
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("%s is wrong. expected=%q. got=%q", tc.input, tc.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestEval_OperatorOverloading(t *testing.T) {
+	vector := `struct Vector {
+	var x = 0
+	var y = 0
+
+	func __add__(other) { Vector(self.x + other.x, self.y + other.y) }
+	func __eq__(other) { self.x == other.x }
+	func __lt__(other) { self.x < other.x }
+	func __index__(i) { if (i == 0) { self.x } else { self.y } }
+	func __len__() { 2 }
+}
+`
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{vector + `Vector(1, 2) + Vector(3, 4)`, "Vector{x: 4, y: 6}"},
+		{vector + `Vector(1, 2) == Vector(1, 5)`, "true"},
+		{vector + `Vector(1, 2) != Vector(1, 5)`, "false"},
+		{vector + `Vector(1, 2) != Vector(2, 2)`, "true"},
+		{vector + `Vector(1, 2) < Vector(2, 0)`, "true"},
+		{vector + `Vector(1, 2)[1]`, "2"},
+		{vector + `len(Vector(1, 2))`, "2"},
+		{vector + `Vector(1, 2) - Vector(1, 2)`, "Exception: UNKNOWN-OPERATOR: INSTANCE - INSTANCE (at 11:14)"},
+		{vector + `Vector(1, 2) + 1`, "Exception: CANNOT ACCESS MEMBER x OF INTEGER (at 5:46)"},
+		{`struct P { var x = 0; func __eq__(o) { 1 } }; P() != P()`, "Exception: __eq__ OF P RETURNED INTEGER. expected=BOOLEAN (at 1:51)"},
+		{`struct P { var x = 0; func __eq__(o) { 1 } }; P() == P()`, "Exception: __eq__ OF P RETURNED INTEGER. expected=BOOLEAN (at 1:51)"},
+		{vector + `Vector(1, 2) in [Vector(1, 5)]`, "true"},
+		{vector + `assert_eq(Vector(1, 2), Vector(1, 5))`, "null"},
+		{`struct P { var x = 0 }; P(1) == P(1)`, "true"},
+		{`struct P { var x = 0 }; P()[0]`, "Exception: ATTEMPTED INDEXING INVALID TYPE INSTANCE (at 1:28)"},
+		{`struct P { var x = 0 }; len(P())`, "Exception: ARGUMENT INVALID TYPE TO BUILT-IN FUNCTION `len`. got=INSTANCE. expected=STRING (at 1:25)"},
+	}
+
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+		if evaluated == nil {
+			t.Errorf("%s evaluated to nil", tc.input)
+			continue
+		}
+
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("%s is wrong. expected=%q. got=%q", tc.input, tc.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestEval_ForLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`var total = 0; for (x in [1, 2, 3]) { var total = total + x }; total`, "6"},
+		{`var out = ""; for (c in "héllo") { var out = c + out }; out`, "olléh"},
+		{`var total = 0; for ([k, v] in {"a": 1, "b": 2}) { var total = total + v }; total`, "3"},
+		{`var total = 0; for (x in #{1, 2, 2}) { var total = total + x }; total`, "3"},
+		{`var total = 0; for (x in range(4)) { var total = total + x }; total`, "6"},
+		{`var f = func() { for (x in [1, 2, 3]) { if (x == 2) { return x } } }; f()`, "2"},
+		{`for ({name} in [{"name": "a"}, {"name": "b"}]) { name }`, "b"},
+		{`for (x in 5) { x }`, "Exception: CANNOT ITERATE OVER INTEGER (at 1:1)"},
+		{`for ([a, b] in [[1, 2], [3]]) { a }`, "Exception: WRONG NUMBER OF ELEMENTS TO DESTRUCTURE. expected=2. got=1 (at 1:6)"},
+		{`for (x in [1, true]) { x + 1 }`, "Exception: TYPE-MISMATCH: BOOLEAN + INTEGER (at 1:26)"},
+		{`struct Pair { var a = 1; var b = 2; func __iter__() { [self.a, self.b] } }; collect(Pair())`, "[1, 2]"},
+		{`struct Empty {}; collect(Empty())`, "Exception: CANNOT ITERATE OVER INSTANCE (at 1:18)"},
+		{`collect(map([1, 2, 3], func(x) { x * 2 }))`, "[2, 4, 6]"},
+		{`collect(take("abc", 2))`, "[a, b]"},
+		{`collect(map([1, "a"], func(x) { x + 1 }))`, "Exception: TYPE-MISMATCH: STRING + INTEGER (at 1:35)"},
+		{`collect(range(2, 5))`, "[2, 3, 4]"},
+		{`range("a")`, "Exception: ARGUMENT INVALID TYPE TO BUILT-IN FUNCTION `range` (argument 0). expected=INTEGER. got=STRING (at 1:1)"},
+	}
+
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+		if evaluated == nil {
+			t.Errorf("%s evaluated to nil", tc.input)
+			continue
+		}
+
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("%s is wrong. expected=%q. got=%q", tc.input, tc.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestEval_Generators(t *testing.T) {
+	naturals := `func naturals() {
+	var n = 0
+	while (true) {
+		yield n
+		var n = n + 1
+	}
+}
+`
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{naturals + `collect(take(naturals(), 3))`, "[0, 1, 2]"},
+		{naturals + `collect(take(map(naturals(), func(x) { x * x }), 4))`, "[0, 1, 4, 9]"},
+		{naturals + `var f = func() { for (n in naturals()) { if (n > 2) { return n } } }; f()`, "3"},
+		{naturals + `var g = naturals(); collect(take(g, 2)); collect(take(g, 2))`, "[]"},
+		{`func pairs(xs) { for (x in xs) { yield [x, x * 2] } }; var out = []; for ([a, b] in pairs([1, 2])) { var out = append(out, a + b) }; out`, "[3, 6]"},
+		{`func g() { yield 1; yield 2; return 5; yield 3 }; collect(g())`, "[1, 2]"},
+		{`func g() { yield 1; 1 + true }; collect(g())`, "Exception: TYPE-MISMATCH: INTEGER + BOOLEAN (at 1:23)"},
+		{`func g(a) { yield a }; g()`, "Exception: WRONG NUMBER OF ARGUMENTS TO FUNCTION `g`. expected=1. got=0 (at 1:24)"},
+		{`func g() { yield 1 }; g()`, "iterator"},
+		{`var started = false; func g() { var started = true; yield 1 }; var it = g(); started`, "false"},
+		{`struct Tree { var items = []; func __iter__() { for (x in self.items) { yield x } } }; collect(Tree([3, 4]))`, "[3, 4]"},
+	}
+
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+		if evaluated == nil {
+			t.Errorf("%s evaluated to nil", tc.input)
+			continue
+		}
+
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("%s is wrong. expected=%q. got=%q", tc.input, tc.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestEval_ShadowingBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`var len = 5`, "Exception: CANNOT SHADOW BUILT-IN FUNCTION `len` (at 1:5)"},
+		{`const print = 1`, "Exception: CANNOT SHADOW BUILT-IN FUNCTION `print` (at 1:7)"},
+		{`func set() {}`, "Exception: CANNOT SHADOW BUILT-IN FUNCTION `set` (at 1:6)"},
+		{`var [a, append] = [1, 2]`, "Exception: CANNOT SHADOW BUILT-IN FUNCTION `append` (at 1:9)"},
+		{`var f = func(a, input) { a + input }; f(1, 2)`, "3"},
+		{`func f(set) { var map = set; map }; f(4)`, "4"},
+		{`var f = func([first, ...range]) { len(range) }; f([1, 2, 3])`, "2"},
+		{`match (1) { len => len }`, "1"},
+		{`if (true) { var len = 1 }`, "Exception: CANNOT SHADOW BUILT-IN FUNCTION `len` (at 1:17)"},
+	}
+
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+		if evaluated == nil {
+			t.Errorf("%s evaluated to nil", tc.input)
+			continue
+		}
+
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("%s is wrong. expected=%q. got=%q", tc.input, tc.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestEval_HashKeyErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{[1, 2]: 5}`, "HASHMAP KEY IS INCORRECT TYPE. got=ARRAY (freeze it to use it as a key)"},
+		{`{"a": 5}[[1]]`, "HASHMAP KEY IS INCORRECT TYPE. got=ARRAY (freeze it to use it as a key)"},
+		{`{freeze([func() {}]): 5}`, "HASHMAP KEY IS INCORRECT TYPE. got=ARRAY"},
+		{`{"a": 5}[func() {}]`, "HASHMAP KEY IS INCORRECT TYPE. got=FUNCTION"},
+	}
+
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+
+		err, ok := evaluated.(*models.Error)
+		if !ok {
+			t.Errorf("object is not models.Error. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if err.Message != tc.expected {
+			t.Errorf("Wrong error received. expected=%q. got=%q", tc.expected, err.Message)
+		}
+	}
+}
+
 func TestEval_HashIndex(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -416,6 +1230,22 @@ func TestEval_HashIndex(t *testing.T) {
 			`{false: 5}[false]`,
 			5,
 		},
+		{
+			`{freeze([1, "a"]): 5}[freeze([1, "a"])]`,
+			5,
+		},
+		{
+			`{freeze([1, [2, 3]]): 5}[freeze([1, [2, 3]])]`,
+			5,
+		},
+		{
+			`{freeze([1, 2]): 5}[freeze([2, 1])]`,
+			nil,
+		},
+		{
+			`var h = freeze({"a": [1]}); {h["a"]: 5}[freeze([1])]`,
+			5,
+		},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
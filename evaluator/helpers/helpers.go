@@ -1,11 +1,14 @@
 package helpers
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/kanersps/loop/ast"
 	"github.com/kanersps/loop/models"
 	"github.com/kanersps/loop/object"
 	"github.com/kanersps/loop/object/builtins"
+	"github.com/kanersps/loop/parser/tokens"
+	"strings"
 )
 
 func Eval(node ast.Node, env *models.Environment) models.Object {
@@ -25,8 +28,21 @@ func Eval(node ast.Node, env *models.Environment) models.Object {
 			return right
 		}
 
-		return evalPrefixExpression(node.Operator, right)
+		if node.Operator == "!" {
+			truth, err := condition(right, env)
+			if err != nil {
+				return withPosition(err, node.Token)
+			}
+
+			return nativeBoolToBooleanObject(!truth)
+		}
+
+		return withPosition(evalPrefixExpression(node.Operator, right), node.Token)
 	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return withPosition(evalLogicalExpression(node, env), node.Token)
+		}
+
 		left := Eval(node.Left, env)
 		right := Eval(node.Right, env)
 
@@ -38,7 +54,7 @@ func Eval(node ast.Node, env *models.Environment) models.Object {
 			return right
 		}
 
-		return evalInfixExpression(node.Operator, left, right)
+		return withPosition(evalInfixExpression(node.Operator, left, right), node.Token)
 	case *ast.BlockStatement:
 		return evalBlockStatement(node, env)
 	case *ast.IfExpression:
@@ -52,6 +68,10 @@ func Eval(node ast.Node, env *models.Environment) models.Object {
 		return &models.Return{Value: value}
 	case *ast.WhileLiteral:
 		return evalWhileExpression(node, env)
+	case *ast.ForLiteral:
+		return evalForExpression(node, env)
+	case *ast.YieldStatement:
+		return evalYieldStatement(node, env)
 	case *ast.VariableStatement:
 		value := Eval(node.Value, env)
 
@@ -59,17 +79,32 @@ func Eval(node ast.Node, env *models.Environment) models.Object {
 			return value
 		}
 
-		env.Set(node.Name.Value, value)
+		if node.Pattern != nil {
+			return destructure(node.Pattern, value, env, node)
+		}
+
+		// `var f = func() {}` names the function after its variable.
+		if fn, ok := value.(*models.Function); ok && fn.Name == "" {
+			if _, literal := node.Value.(*ast.FunctionLiteral); literal {
+				fn.Name = node.Name.Value
+			}
+		}
+
+		return declare(node.Name, value, env, node)
+	case *ast.FunctionStatement:
+		// Already bound by hoistFunctions when the block started.
+		return nil
+	case *ast.StructStatement:
+		return evalStructStatement(node, env)
+	case *ast.AssignStatement:
+		return evalAssignStatement(node, env)
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return withPosition(evalIdentifier(node, env), node.Token)
 	case *ast.FunctionLiteral:
-		params := node.Parameters
-		body := node.Body
-		return &models.Function{
-			Parameters: params,
-			Body:       body,
-			Env:        env,
-		}
+		fn := newFunction(node, env)
+		fn.Expression = true
+
+		return fn
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
 		if isError(function) {
@@ -82,9 +117,11 @@ func Eval(node ast.Node, env *models.Environment) models.Object {
 			return args[0]
 		}
 
-		return ApplyFunction(function, args, env)
+		return withPosition(ApplyFunction(function, args, env), callToken(node))
 	case *ast.StringLiteral:
 		return &models.String{Value: node.Value}
+	case *ast.TemplateLiteral:
+		return evalTemplateLiteral(node, env)
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
@@ -105,47 +142,135 @@ func Eval(node ast.Node, env *models.Environment) models.Object {
 			return index
 		}
 
-		return evalIndexExpression(left, index)
+		return withPosition(evalIndexExpression(left, index), node.Token)
+	case *ast.MemberExpression:
+		object := Eval(node.Object, env)
+
+		if isError(object) {
+			return object
+		}
+
+		return withPosition(evalMemberExpression(object, node.Member.Value), node.Member.Token)
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
+	case *ast.SetLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+
+		set, invalid := models.NewSet(elements...)
+		if invalid != nil {
+			return withPosition(throwError("SET MEMBER IS INCORRECT TYPE. got=%s", invalid.Type()), node.Token)
+		}
+
+		return set
 	}
 	return nil
 }
 
+// newFunction creates the function a literal evaluates to in env.
+func newFunction(node *ast.FunctionLiteral, env *models.Environment) *models.Function {
+	name := ""
+	if node.Name != nil {
+		name = node.Name.Value
+	}
+
+	return &models.Function{
+		Name:       name,
+		Parameters: node.Parameters,
+		Patterns:   node.Patterns,
+		Defaults:   node.Defaults,
+		Rest:       node.Rest,
+		Body:       node.Body,
+		Env:        env,
+		Generator:  node.Generator,
+	}
+}
+
 func throwError(format string, a ...interface{}) *models.Error {
 	return &models.Error{Message: fmt.Sprintf(format, a...)}
 }
 
+// withPosition records where an error was raised. Errors keep the first
+// position they are given, which is the innermost expression that failed.
+func withPosition(obj models.Object, token tokens.Token) models.Object {
+	if err, ok := obj.(*models.Error); ok && err.Line == 0 {
+		err.Line = token.Line
+		err.Column = token.Column
+	}
+
+	return obj
+}
+
+// callToken is the token a failed call is reported at: the name of the
+// function when it is called by name, the opening parenthesis otherwise.
+func callToken(node *ast.CallExpression) tokens.Token {
+	if ident, ok := node.Function.(*ast.Identifier); ok {
+		return ident.Token
+	}
+
+	return node.Token
+}
+
+func evalTemplateLiteral(node *ast.TemplateLiteral, env *models.Environment) models.Object {
+	var out bytes.Buffer
+
+	for _, part := range node.Parts {
+		value := Eval(part, env)
+		if isError(value) {
+			return value
+		}
+
+		if value == nil {
+			value = models.NULL
+		}
+
+		out.WriteString(value.Inspect())
+	}
+
+	return &models.String{Value: out.String()}
+}
+
 func evalHashLiteral(node *ast.HashLiteral, env *models.Environment) models.Object {
-	pairs := make(map[models.HashKey]models.HashPair)
+	hash := &models.Hash{Pairs: make(map[models.HashKey]models.HashPair)}
 
-	for keyNode, valueNode := range node.Pairs {
+	for _, keyNode := range node.Keys {
 		key := Eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
 
-		hashKey, ok := key.(models.Hashable)
-		if !ok {
-			return throwError("HASHMAP KEY IS INCORRECT TYPE. got=%s", key.Type())
+		if _, ok := models.HashKeyOf(key); !ok {
+			return unusableHashKey(key)
 		}
 
-		value := Eval(valueNode, env)
+		value := Eval(node.Pairs[keyNode], env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = models.HashPair{
-			Key:   key,
-			Value: value,
-		}
+		hash.Set(key, value)
 	}
 
-	return &models.Hash{Pairs: pairs}
+	return hash
+}
+
+func unusableHashKey(key models.Object) *models.Error {
+	if array, ok := key.(*models.Array); ok && !array.Frozen {
+		return throwError("HASHMAP KEY IS INCORRECT TYPE. got=ARRAY (freeze it to use it as a key)")
+	}
+
+	return throwError("HASHMAP KEY IS INCORRECT TYPE. got=%s", key.Type())
 }
 
 func evalIndexExpression(left, index models.Object) models.Object {
+	if result, ok := callMethod(left, "__index__", index); ok {
+		return result
+	}
+
 	if left.Type() == models.ARRAY {
 		if index.Type() != models.INTEGER {
 			return throwError("INVALID INDEX. expected=INTEGER. got=%s", index.Type())
@@ -158,51 +283,273 @@ func evalIndexExpression(left, index models.Object) models.Object {
 		return evalHashIndexExpression(left, index)
 	}
 
+	if left.Type() == models.STRING {
+		if index.Type() != models.INTEGER {
+			return throwError("INVALID INDEX. expected=INTEGER. got=%s", index.Type())
+		}
+
+		return evalStringIndexExpression(left, index)
+	}
+
 	return throwError("ATTEMPTED INDEXING INVALID TYPE %s", left.Type())
 }
 
 func evalHashIndexExpression(hash, index models.Object) models.Object {
-	hashObj := hash.(*models.Hash)
-
-	idx := index.(models.Hashable).HashKey()
+	if _, ok := models.HashKeyOf(index); !ok {
+		return unusableHashKey(index)
+	}
 
-	pair, ok := hashObj.Pairs[idx]
+	value, ok := hash.(*models.Hash).Get(index)
 	if !ok {
 		return models.NULL
 	}
 
-	return pair.Value
+	return value
+}
+
+// evalStringIndexExpression indexes by rune rather than by byte, returning
+// null when the index is out of range.
+func evalStringIndexExpression(str, index models.Object) models.Object {
+	runes := []rune(str.(*models.String).Value)
+	idx := index.(*models.Integer).Value
+
+	if idx < 0 || idx >= int64(len(runes)) {
+		return models.NULL
+	}
+
+	return &models.String{Value: string(runes[idx])}
 }
 
+// evalArrayIndexExpression returns null when the index is out of range, like
+// indexing a string.
 func evalArrayIndexExpression(array, index models.Object) models.Object {
 	arrayObj := array.(*models.Array)
 	idx := index.(*models.Integer).Value
 
+	if idx < 0 || idx >= int64(len(arrayObj.Elements)) {
+		return models.NULL
+	}
+
 	return arrayObj.Elements[idx]
 }
 
 func ApplyFunction(fn models.Object, args []models.Object, env *models.Environment) models.Object {
 	switch fn := fn.(type) {
 	case *models.Function:
-		extendedEnv := extendedFunctionEnv(fn, args)
+		extendedEnv, err := extendedFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
+
+		if fn.Generator {
+			return generate(fn, extendedEnv)
+		}
+
 		evaluated := Eval(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 	case *models.Builtin:
 		builtins.SetApplyFunction(ApplyFunction)
 		return fn.Func(env, args...)
+	case *models.Struct:
+		return instantiate(fn, args, env)
 	default:
 		return throwError("UNKNOWN-FUNCTION: %s", fn.Type())
 	}
 }
 
-func extendedFunctionEnv(fn *models.Function, args []models.Object) *models.Environment {
+func init() {
+	// for loops iterate over instances by calling their __iter__ method.
+	builtins.SetApplyFunction(ApplyFunction)
+}
+
+// extendedFunctionEnv binds the arguments of a call to the function's
+// parameters. Missing arguments take their default, which is evaluated in
+// the new environment so it can refer to earlier parameters, and any extra
+// arguments are collected by the rest parameter.
+func extendedFunctionEnv(fn *models.Function, args []models.Object) (*models.Environment, *models.Error) {
+	required := 0
+	for i := range fn.Parameters {
+		if i >= len(fn.Defaults) || fn.Defaults[i] == nil {
+			required = i + 1
+		}
+	}
+
+	if len(args) < required || (fn.Rest == nil && len(args) > len(fn.Parameters)) {
+		expected := fmt.Sprintf("%d", len(fn.Parameters))
+
+		switch {
+		case fn.Rest != nil:
+			expected = fmt.Sprintf("at least %d", required)
+		case required != len(fn.Parameters):
+			expected = fmt.Sprintf("%d to %d", required, len(fn.Parameters))
+		}
+
+		if fn.Name != "" {
+			return nil, throwError("WRONG NUMBER OF ARGUMENTS TO FUNCTION `%s`. expected=%s. got=%d", fn.Name, expected, len(args))
+		}
+
+		return nil, throwError("WRONG NUMBER OF ARGUMENTS TO FUNCTION. expected=%s. got=%d", expected, len(args))
+	}
+
 	env := object.NewEnclosedEnvironment(fn.Env)
 
+	// A named function expression can call itself by its name, which is only
+	// bound inside the function. Parameters of the same name take precedence.
+	if fn.Expression && fn.Name != "" {
+		env.Store[fn.Name] = fn
+	}
+
+	if fn.Self != nil {
+		env.Store["self"] = fn.Self
+	}
+
 	for paramId, param := range fn.Parameters {
-		env.Set(param.Value, args[paramId])
+		var value models.Object
+
+		if paramId < len(args) {
+			value = args[paramId]
+		} else {
+			value = Eval(fn.Defaults[paramId], env)
+			if err, ok := value.(*models.Error); ok {
+				return nil, err
+			}
+		}
+
+		if paramId < len(fn.Patterns) && fn.Patterns[paramId] != nil {
+			if err, ok := destructure(fn.Patterns[paramId], value, env, nil).(*models.Error); ok {
+				return nil, err
+			}
+			continue
+		}
+
+		if err, ok := declare(param, value, env, nil).(*models.Error); ok {
+			return nil, err
+		}
+	}
+
+	if fn.Rest != nil {
+		rest := []models.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+
+		if err, ok := declare(fn.Rest, &models.Array{Elements: rest}, env, nil).(*models.Error); ok {
+			return nil, err
+		}
+	}
+
+	return env, nil
+}
+
+// evalStructStatement binds the name of a struct to its type. Its methods
+// close over the environment the struct is declared in, like functions.
+func evalStructStatement(node *ast.StructStatement, env *models.Environment) models.Object {
+	definition := &models.Struct{
+		Name:    node.Name.Value,
+		Fields:  node.Fields(),
+		Methods: map[string]*models.Function{},
+		Env:     env,
+	}
+
+	for _, method := range node.Methods() {
+		fn := newFunction(method, env)
+		fn.Name = definition.Name + "." + method.Name.Value
+		definition.Methods[method.Name.Value] = fn
+	}
+
+	if err := checkShadowing(node.Name, env); err != nil {
+		return err
+	}
+
+	return declare(node.Name, definition, env, nil)
+}
+
+// instantiate creates an instance of a struct. Its fields start out with
+// their defaults, which are evaluated again for every instance. The
+// arguments are passed to the init method when the struct has one and
+// otherwise set the fields in the order they are declared.
+func instantiate(definition *models.Struct, args []models.Object, env *models.Environment) models.Object {
+	instance := &models.Instance{Struct: definition, Fields: make(map[string]models.Object, len(definition.Fields))}
+
+	for _, field := range definition.Fields {
+		value := Eval(field.Value, definition.Env)
+		if isError(value) {
+			return value
+		}
+
+		instance.Fields[field.Name.Value] = value
+	}
+
+	if init, ok := instance.Method("init"); ok {
+		if result := ApplyFunction(init, args, env); isError(result) {
+			return result
+		}
+
+		return instance
+	}
+
+	if len(args) > len(definition.Fields) {
+		return throwError("WRONG NUMBER OF ARGUMENTS TO STRUCT `%s`. expected=0 to %d. got=%d", definition.Name, len(definition.Fields), len(args))
+	}
+
+	for i, arg := range args {
+		instance.Fields[definition.Fields[i].Name.Value] = arg
+	}
+
+	return instance
+}
+
+// evalMemberExpression looks up a field of an instance, or else one of its
+// methods.
+func evalMemberExpression(obj models.Object, name string) models.Object {
+	instance, ok := obj.(*models.Instance)
+	if !ok {
+		return throwError("CANNOT ACCESS MEMBER %s OF %s", name, obj.Type())
+	}
+
+	if value, ok := instance.Fields[name]; ok {
+		return value
+	}
+
+	if method, ok := instance.Method(name); ok {
+		return method
+	}
+
+	return throwError("UNKNOWN MEMBER %s OF %s", name, instance.Struct.Name)
+}
+
+// evalAssignStatement sets a field of an instance. Only declared fields can
+// be assigned to, and only while the instance is not frozen.
+func evalAssignStatement(node *ast.AssignStatement, env *models.Environment) models.Object {
+	target := Eval(node.Target.Object, env)
+	if isError(target) {
+		return target
+	}
+
+	value := Eval(node.Value, env)
+	if isError(value) {
+		return value
+	}
+
+	name := node.Target.Member
+
+	instance, ok := target.(*models.Instance)
+	if !ok {
+		return withPosition(throwError("CANNOT ASSIGN TO MEMBER %s OF %s", name.Value, target.Type()), name.Token)
 	}
 
-	return env
+	_, declared := instance.Fields[name.Value]
+
+	switch {
+	case !declared:
+		return withPosition(throwError("UNKNOWN FIELD %s OF %s", name.Value, instance.Struct.Name), name.Token)
+	case instance.Frozen:
+		return withPosition(throwError("CANNOT ASSIGN TO FIELD %s OF FROZEN %s", name.Value, instance.Struct.Name), name.Token)
+	}
+
+	instance.Fields[name.Value] = value
+
+	return nil
 }
 
 func unwrapReturnValue(obj models.Object) models.Object {
@@ -217,13 +564,30 @@ func evalExpressions(expressions []ast.Expression, env *models.Environment) []mo
 	var results []models.Object
 
 	for _, exp := range expressions {
+		spread, isSpread := exp.(*ast.SpreadExpression)
+		if isSpread {
+			exp = spread.Value
+		}
+
 		evaluated := Eval(exp, env)
 
 		if isError(evaluated) {
 			return []models.Object{evaluated}
 		}
 
-		results = append(results, evaluated)
+		if !isSpread {
+			results = append(results, evaluated)
+			continue
+		}
+
+		switch evaluated := evaluated.(type) {
+		case *models.Array:
+			results = append(results, evaluated.Elements...)
+		case *models.Set:
+			results = append(results, evaluated.Elements...)
+		default:
+			return []models.Object{withPosition(throwError("CANNOT SPREAD %s", evaluated.Type()), spread.Token)}
+		}
 	}
 
 	return results
@@ -242,14 +606,59 @@ func evalIdentifier(node *ast.Identifier, env *models.Environment) models.Object
 	return value
 }
 
+// condition decides whether a value counts as true. In strict mode only
+// booleans are accepted, otherwise models.IsTruthy applies.
+func condition(value models.Object, env *models.Environment) (bool, *models.Error) {
+	if env.IsStrict() && value.Type() != models.BOOLEAN {
+		return false, throwError("NON-BOOLEAN CONDITION: %s", value.Type())
+	}
+
+	return models.IsTruthy(value), nil
+}
+
+// evalLogicalExpression evaluates && and ||, skipping the right operand when
+// the left one decides the result.
+func evalLogicalExpression(node *ast.InfixExpression, env *models.Environment) models.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	truth, err := condition(left, env)
+	if err != nil {
+		return err
+	}
+
+	if truth == (node.Operator == "||") {
+		return nativeBoolToBooleanObject(truth)
+	}
+
+	right := Eval(node.Right, env)
+	if isError(right) {
+		return right
+	}
+
+	truth, err = condition(right, env)
+	if err != nil {
+		return err
+	}
+
+	return nativeBoolToBooleanObject(truth)
+}
+
 func evalIfExpression(node *ast.IfExpression, env *models.Environment) models.Object {
-	condition := Eval(node.Condition, env)
+	value := Eval(node.Condition, env)
 
-	if isError(condition) {
-		return condition
+	if isError(value) {
+		return value
 	}
 
-	if condition == models.TRUE {
+	truth, err := condition(value, env)
+	if err != nil {
+		return withPosition(err, node.Token)
+	}
+
+	if truth {
 		return Eval(node.Consequence, env)
 	} else if node.Alternative != nil {
 		return Eval(node.Alternative, env)
@@ -258,24 +667,386 @@ func evalIfExpression(node *ast.IfExpression, env *models.Environment) models.Ob
 	}
 }
 
+func evalMatchExpression(node *ast.MatchExpression, env *models.Environment) models.Object {
+	value := Eval(node.Value, env)
+
+	if isError(value) {
+		return value
+	}
+
+	for _, arm := range node.Arms {
+		bindings := map[*ast.Identifier]models.Object{}
+
+		if !matchPattern(arm.Pattern, value, env, bindings) {
+			continue
+		}
+
+		// The names a pattern binds only exist in its arm. They are stored
+		// directly since Set would also assign an outer variable of the same
+		// name.
+		armEnv := object.NewEnclosedEnvironment(env)
+
+		for name, bound := range bindings {
+			armEnv.Store[name.Value] = bound
+		}
+
+		return Eval(arm.Body, armEnv)
+	}
+
+	return models.NULL
+}
+
+// matchPattern reports whether value has the shape of pattern, collecting the
+// values of the names the pattern binds. The parser only lets valid patterns
+// through.
+func matchPattern(pattern ast.Expression, value models.Object, env *models.Environment, bindings map[*ast.Identifier]models.Object) bool {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value != "_" {
+			bindings[pattern] = value
+		}
+
+		return true
+	case *ast.ArrayLiteral:
+		array, ok := value.(*models.Array)
+		if !ok {
+			return false
+		}
+
+		elements := pattern.Elements
+		var rest *ast.SpreadExpression
+
+		if len(elements) > 0 {
+			rest, _ = elements[len(elements)-1].(*ast.SpreadExpression)
+		}
+
+		if rest != nil {
+			elements = elements[:len(elements)-1]
+			if len(array.Elements) < len(elements) {
+				return false
+			}
+
+			remaining := make([]models.Object, len(array.Elements)-len(elements))
+			copy(remaining, array.Elements[len(elements):])
+
+			if !matchPattern(rest.Value, &models.Array{Elements: remaining}, env, bindings) {
+				return false
+			}
+		} else if len(array.Elements) != len(elements) {
+			return false
+		}
+
+		for idx, element := range elements {
+			if !matchPattern(element, array.Elements[idx], env, bindings) {
+				return false
+			}
+		}
+
+		return true
+	case *ast.HashLiteral:
+		hash, ok := value.(*models.Hash)
+		if !ok {
+			return false
+		}
+
+		for _, key := range pattern.Keys {
+			element, ok := hash.Get(Eval(key, env))
+			if !ok || !matchPattern(pattern.Pairs[key], element, env, bindings) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return models.Equal(Eval(pattern, env), value)
+}
+
+// destructure binds the names in a var, const or parameter pattern to the
+// parts of value, failing when value does not have the pattern's shape.
+// Unlike a match it binds each name as soon as it is reached.
+func destructure(pattern ast.Expression, value models.Object, env *models.Environment, statement *ast.VariableStatement) models.Object {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value == "_" {
+			return nil
+		}
+
+		return declare(pattern, value, env, statement)
+	case *ast.ArrayLiteral:
+		array, ok := value.(*models.Array)
+		if !ok {
+			return withPosition(throwError("CANNOT DESTRUCTURE %s AS ARRAY", value.Type()), pattern.Token)
+		}
+
+		elements := pattern.Elements
+		var rest *ast.SpreadExpression
+
+		if len(elements) > 0 {
+			rest, _ = elements[len(elements)-1].(*ast.SpreadExpression)
+		}
+
+		if rest != nil {
+			elements = elements[:len(elements)-1]
+		}
+
+		if len(array.Elements) < len(elements) || (rest == nil && len(array.Elements) > len(elements)) {
+			expected := fmt.Sprintf("%d", len(elements))
+			if rest != nil {
+				expected = "at least " + expected
+			}
+
+			return withPosition(throwError("WRONG NUMBER OF ELEMENTS TO DESTRUCTURE. expected=%s. got=%d", expected, len(array.Elements)), pattern.Token)
+		}
+
+		for idx, element := range elements {
+			if err := destructure(element, array.Elements[idx], env, statement); err != nil {
+				return err
+			}
+		}
+
+		if rest != nil {
+			remaining := make([]models.Object, len(array.Elements)-len(elements))
+			copy(remaining, array.Elements[len(elements):])
+
+			return destructure(rest.Value, &models.Array{Elements: remaining}, env, statement)
+		}
+
+		return nil
+	case *ast.HashLiteral:
+		hash, ok := value.(*models.Hash)
+		if !ok {
+			return withPosition(throwError("CANNOT DESTRUCTURE %s AS HASH", value.Type()), pattern.Token)
+		}
+
+		for _, key := range pattern.Keys {
+			keyValue := Eval(key, env)
+			element, ok := hash.Get(keyValue)
+			if !ok {
+				return withPosition(throwError("CANNOT DESTRUCTURE MISSING KEY %s", keyValue.Inspect()), ast.FirstToken(key))
+			}
+
+			if err := destructure(pattern.Pairs[key], element, env, statement); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return nil
+}
+
 func evalWhileExpression(node *ast.WhileLiteral, env *models.Environment) models.Object {
-	condition := Eval(node.Condition, env)
+	var lastEvaluation models.Object
+
+	for {
+		value := Eval(node.Condition, env)
+
+		if isError(value) {
+			return value
+		}
+
+		truth, err := condition(value, env)
+		if err != nil {
+			return withPosition(err, node.Token)
+		}
+
+		if !truth {
+			return lastEvaluation
+		}
+
+		lastEvaluation = Eval(node.Body, env)
+
+		// A return or an error inside the body ends the loop.
+		if lastEvaluation != nil {
+			if rt := lastEvaluation.Type(); rt == models.RETURN || rt == models.ERROR {
+				return lastEvaluation
+			}
+		}
+	}
+}
+
+// evalForExpression runs the body once for every value of the iterable,
+// binding the value in the enclosing environment like a var statement. A
+// return or an error ends the loop early.
+func evalForExpression(node *ast.ForLiteral, env *models.Environment) models.Object {
+	iterable := Eval(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
 
-	if isError(condition) {
-		return condition
+	iterator, err := builtins.Iterate(iterable)
+	if err != nil {
+		return withPosition(err, node.Token)
 	}
+	defer iterator.Close()
 
 	var lastEvaluation models.Object
 
-	for condition == models.TRUE {
+	for value := iterator.Next(); value != nil; value = iterator.Next() {
+		if isError(value) {
+			return value
+		}
+
+		if err := destructure(node.Pattern, value, env, nil); err != nil {
+			return err
+		}
+
 		lastEvaluation = Eval(node.Body, env)
-		condition = Eval(node.Condition, env)
+
+		if lastEvaluation != nil {
+			if rt := lastEvaluation.Type(); rt == models.RETURN || rt == models.ERROR {
+				return lastEvaluation
+			}
+		}
 	}
 
 	return lastEvaluation
 }
 
+// generate calls a generator. Its body runs on a goroutine of its own that
+// only runs while the caller waits for the next value, so the two never run
+// at the same time. When the iterator is stopped before the body finishes,
+// the pending yield returns out of the body.
+func generate(fn *models.Function, env *models.Environment) *models.Iterator {
+	values := make(chan models.Object)
+	resume := make(chan struct{})
+	stop := make(chan struct{})
+
+	env.Yield = func(value models.Object) bool {
+		select {
+		case values <- value:
+		case <-stop:
+			return false
+		}
+
+		select {
+		case <-resume:
+			return true
+		case <-stop:
+			return false
+		}
+	}
+
+	run := func() {
+		defer close(values)
+
+		if err, ok := Eval(fn.Body, env).(*models.Error); ok {
+			select {
+			case values <- err:
+			case <-stop:
+			}
+		}
+	}
+
+	started, finished := false, false
+
+	return &models.Iterator{
+		Next: func() models.Object {
+			if finished {
+				return nil
+			}
+
+			if started {
+				resume <- struct{}{}
+			} else {
+				started = true
+				go run()
+			}
+
+			value, ok := <-values
+			if !ok || isError(value) {
+				finished = true
+			}
+
+			if !ok {
+				return nil
+			}
+
+			return value
+		},
+		Stop: func() {
+			if !finished {
+				finished = true
+				close(stop)
+			}
+		},
+	}
+}
+
+func evalYieldStatement(node *ast.YieldStatement, env *models.Environment) models.Object {
+	value := Eval(node.Value, env)
+	if isError(value) {
+		return value
+	}
+
+	yield := env.Yielder()
+	if yield == nil {
+		return withPosition(throwError("YIELD OUTSIDE OF A GENERATOR"), node.Token)
+	}
+
+	if !yield(value) {
+		return &models.Return{Value: models.NULL}
+	}
+
+	return nil
+}
+
+// operatorMethods are the methods a struct defines to overload an operator
+// for its instances. == and != call __eq__ through builtins.Equal instead.
+var operatorMethods = map[string]string{
+	"+": "__add__",
+	"-": "__sub__",
+	"*": "__mul__",
+	"/": "__div__",
+	"<": "__lt__",
+	">": "__gt__",
+}
+
+// callMethod calls the special method name of obj with args. ok is false
+// when obj is not an instance or its struct does not define the method.
+func callMethod(obj models.Object, name string, args ...models.Object) (result models.Object, ok bool) {
+	instance, ok := obj.(*models.Instance)
+	if !ok {
+		return nil, false
+	}
+
+	method, ok := instance.Method(name)
+	if !ok {
+		return nil, false
+	}
+
+	return ApplyFunction(method, args, instance.Struct.Env), true
+}
+
+// evalInfixExpression applies a binary operator. == and != compare values,
+// deeply for arrays and hashes, while `is` checks that both sides are the
+// same object.
 func evalInfixExpression(operator string, left models.Object, right models.Object) models.Object {
+	// An instance on the left overloads the operator by defining its
+	// method. != is the negation of __eq__.
+	if result, ok := callMethod(left, operatorMethods[operator], right); ok {
+		return result
+	}
+
+	if operator == "==" || operator == "!=" {
+		equal, err := builtins.Equal(left, right)
+		if err != nil {
+			return err
+		}
+
+		return nativeBoolToBooleanObject(equal == (operator == "=="))
+	}
+
+	if operator == "is" {
+		return nativeBoolToBooleanObject(left == right)
+	}
+
+	if operator == "in" {
+		return evalInExpression(left, right)
+	}
+
 	if left.Type() == models.INTEGER && right.Type() == models.INTEGER {
 		return evalIntegerInfixExpression(operator, left, right)
 	}
@@ -284,20 +1055,50 @@ func evalInfixExpression(operator string, left models.Object, right models.Objec
 		return &models.String{Value: left.Inspect() + right.Inspect()}
 	}
 
-	switch operator {
-	case "==":
-		return nativeBoolToBooleanObject(left == right)
-	case "!=":
-		return nativeBoolToBooleanObject(left != right)
-	}
-
 	if left.Type() != right.Type() {
 		return throwError("TYPE-MISMATCH: %s %s %s", left.Type(), operator, right.Type())
 	}
 
+	if operator == "<" || operator == ">" {
+		if result, ok := models.Compare(left, right); ok {
+			return nativeBoolToBooleanObject((operator == "<" && result < 0) || (operator == ">" && result > 0))
+		}
+	}
+
 	return throwError("UNKNOWN-OPERATOR: %s %s %s", left.Type(), operator, right.Type())
 }
 
+// evalInExpression checks membership: an element of an array, compared like
+// ==, a key of a hash, a member of a set or a substring of a string.
+func evalInExpression(member, collection models.Object) models.Object {
+	switch collection := collection.(type) {
+	case *models.Set:
+		return nativeBoolToBooleanObject(collection.Has(member))
+	case *models.Hash:
+		_, ok := collection.Get(member)
+		return nativeBoolToBooleanObject(ok)
+	case *models.Array:
+		for _, element := range collection.Elements {
+			equal, err := builtins.Equal(member, element)
+			if err != nil {
+				return err
+			}
+
+			if equal {
+				return models.TRUE
+			}
+		}
+
+		return models.FALSE
+	case *models.String:
+		if member, ok := member.(*models.String); ok {
+			return nativeBoolToBooleanObject(strings.Contains(collection.Value, member.Value))
+		}
+	}
+
+	return throwError("UNKNOWN-OPERATOR: %s in %s", member.Type(), collection.Type())
+}
+
 func evalIntegerInfixExpression(operator string, left, right models.Object) models.Object {
 	lv := left.(*models.Integer).Value
 	rv := right.(*models.Integer).Value
@@ -308,6 +1109,10 @@ func evalIntegerInfixExpression(operator string, left, right models.Object) mode
 	case "*":
 		return &models.Integer{Value: lv * rv}
 	case "/":
+		if rv == 0 {
+			return throwError("DIVISION BY ZERO")
+		}
+
 		return &models.Integer{Value: lv / rv}
 	case "-":
 		return &models.Integer{Value: lv - rv}
@@ -315,10 +1120,6 @@ func evalIntegerInfixExpression(operator string, left, right models.Object) mode
 		return nativeBoolToBooleanObject(lv < rv)
 	case ">":
 		return nativeBoolToBooleanObject(lv > rv)
-	case "==":
-		return nativeBoolToBooleanObject(lv == rv)
-	case "!=":
-		return nativeBoolToBooleanObject(lv != rv)
 	}
 
 	return throwError("UNKNOWN-OPERATOR: %s %s %s", left.Type(), operator, right.Type())
@@ -327,6 +1128,10 @@ func evalIntegerInfixExpression(operator string, left, right models.Object) mode
 func evalBlockStatement(block *ast.BlockStatement, env *models.Environment) models.Object {
 	var result models.Object
 
+	if err := hoistFunctions(block.Statements, env); err != nil {
+		return err
+	}
+
 	for _, statement := range block.Statements {
 		result = Eval(statement, env)
 
@@ -343,8 +1148,6 @@ func evalBlockStatement(block *ast.BlockStatement, env *models.Environment) mode
 
 func evalPrefixExpression(operator string, right models.Object) models.Object {
 	switch operator {
-	case "!":
-		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusPrefixOperator(right)
 	default:
@@ -352,19 +1155,6 @@ func evalPrefixExpression(operator string, right models.Object) models.Object {
 	}
 }
 
-func evalBangOperatorExpression(right models.Object) models.Object {
-	switch right {
-	case models.TRUE:
-		return models.FALSE
-	case models.FALSE:
-		return models.TRUE
-	case models.NULL:
-		return models.TRUE
-	default:
-		return models.FALSE
-	}
-}
-
 func evalMinusPrefixOperator(right models.Object) models.Object {
 	if right.Type() != models.INTEGER {
 		return throwError("UNKNOWN-OPERATOR: -%s", right.Type())
@@ -388,8 +1178,68 @@ func nativeBoolToBooleanObject(input bool) *models.Boolean {
 	return models.FALSE
 }
 
+// hoistFunctions binds the functions declared in a block before any of its
+// statements run, so they can be called before their declaration and call
+// each other regardless of order.
+func hoistFunctions(stmts []ast.Statement, env *models.Environment) models.Object {
+	for _, statement := range stmts {
+		if declaration, ok := statement.(*ast.FunctionStatement); ok {
+			if err := checkShadowing(declaration.Function.Name, env); err != nil {
+				return err
+			}
+
+			if err := declare(declaration.Function.Name, newFunction(declaration.Function, env), env, nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// declare binds a name declared by a var or const statement, a function
+// declaration, a parameter or a pattern. statement is the var or const
+// statement, if any. A constant cannot be assigned again, except by running
+// its own declaration again as in a loop body.
+func declare(name *ast.Identifier, value models.Object, env *models.Environment, statement *ast.VariableStatement) models.Object {
+	if declaration, ok := env.Constant(name.Value); ok && declaration != ast.Node(statement) {
+		return withPosition(throwError("CANNOT REASSIGN CONSTANT %s", name.Value), name.Token)
+	}
+
+	if statement != nil {
+		if err := checkShadowing(name, env); err != nil {
+			return err
+		}
+	}
+
+	if statement != nil && statement.Constant() {
+		env.SetConstant(name.Value, value, statement)
+	} else {
+		env.Set(name.Value, value)
+	}
+
+	return nil
+}
+
+// checkShadowing rejects a top-level declaration named after a builtin,
+// which would hide the builtin from the whole program, unless the
+// environment allows it. Names declared inside a function, such as its
+// parameters, only hide the builtin there and are always allowed.
+func checkShadowing(name *ast.Identifier, env *models.Environment) models.Object {
+	if _, ok := builtins.Functions[name.Value]; ok && env.Outer == nil && !env.IsShadowingAllowed() {
+		return withPosition(throwError("CANNOT SHADOW BUILT-IN FUNCTION `%s`", name.Value), name.Token)
+	}
+
+	return nil
+}
+
 func evalProgram(stmts []ast.Statement, env *models.Environment) models.Object {
 	var result models.Object
+
+	if err := hoistFunctions(stmts, env); err != nil {
+		return err
+	}
+
 	for _, statement := range stmts {
 		result = Eval(statement, env)
 
@@ -0,0 +1,588 @@
+package format
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/kanersps/loop/ast"
+	"github.com/kanersps/loop/parser"
+	"github.com/kanersps/loop/parser/lexer"
+	"github.com/kanersps/loop/parser/tokens"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	indentation = "    "
+	maxWidth    = 80
+	primary     = parser.INDEX + 1
+)
+
+var operatorPrecedences = map[string]int{
+	"||": parser.OR,
+	"&&": parser.AND,
+	"==": parser.EQUALS,
+	"!=": parser.EQUALS,
+	"is": parser.EQUALS,
+	"in": parser.EQUALS,
+	"<":  parser.LESSGREATER,
+	">":  parser.LESSGREATER,
+	"+":  parser.SUM,
+	"-":  parser.SUM,
+	"*":  parser.PRODUCT,
+	"/":  parser.PRODUCT,
+}
+
+// Source parses src and returns it printed in canonical form. It fails
+// rather than drop a comment it has no place for, such as one before a comma.
+func Source(src []byte) ([]byte, error) {
+	l := lexer.Create(string(src))
+	l.RetainComments()
+	p := parser.Create(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	var out bytes.Buffer
+	if err := Fprint(&out, program); err != nil {
+		return nil, err
+	}
+
+	// Comments can only be kept where the printer knows about them, so
+	// refuse to format rather than lose one.
+	formatted := comments(out.String())
+	for idx, comment := range comments(string(src)) {
+		if idx >= len(formatted) || formatted[idx].Value != comment.Value {
+			return nil, fmt.Errorf("%d:%d: cannot format the comment %s at this position", comment.Line, comment.Column, comment.Value)
+		}
+	}
+
+	return out.Bytes(), nil
+}
+
+// comments returns every comment in src in order.
+func comments(src string) []tokens.Comment {
+	l := lexer.Create(src)
+	l.RetainComments()
+
+	var comments []tokens.Comment
+	for {
+		token := l.FindToken()
+		comments = append(comments, token.Comments...)
+
+		if token.TokenType == tokens.EOF {
+			return comments
+		}
+	}
+}
+
+// Fprint writes node to w as Loop source. Statements are separated by
+// newlines, keeping at most one blank line where the source had any, and a
+// semicolon is only written when the next statement would otherwise continue
+// the previous one.
+func Fprint(w io.Writer, node ast.Node) error {
+	p := &printer{printed: map[*tokens.Comment]bool{}}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		p.statements(node.Statements, node.End, false)
+		if p.out.Len() != 0 {
+			p.write("\n")
+		}
+	case ast.Statement:
+		p.statements([]ast.Statement{node}, tokens.Token{}, false)
+	case ast.Expression:
+		p.expression(node, parser.LOWEST)
+	}
+
+	_, err := w.Write(p.out.Bytes())
+	return err
+}
+
+type printer struct {
+	out    bytes.Buffer
+	indent int
+	// printed holds the first comment of every list of comments that has
+	// been written, since an expression shares its first token with the
+	// statement or the larger expression it starts.
+	printed map[*tokens.Comment]bool
+}
+
+func (p *printer) write(values ...string) {
+	for _, value := range values {
+		p.out.WriteString(value)
+	}
+}
+
+func (p *printer) newline() {
+	p.write("\n", strings.Repeat(indentation, p.indent))
+}
+
+// sub returns a printer that renders at the same indentation into its own
+// buffer, so a construct can be measured before it is laid out.
+func (p *printer) sub() *printer {
+	printed := make(map[*tokens.Comment]bool, len(p.printed))
+	for comment := range p.printed {
+		printed[comment] = true
+	}
+
+	return &printer{indent: p.indent, printed: printed}
+}
+
+// claim marks comments as written and reports whether they still had to be.
+func (p *printer) claim(comments []tokens.Comment) bool {
+	if len(comments) == 0 || p.printed[&comments[0]] {
+		return false
+	}
+
+	p.printed[&comments[0]] = true
+	return true
+}
+
+// inline writes comments that appear in the middle of a line. A line comment
+// ends the line, and the rest continues indented by continuation levels.
+func (p *printer) inline(comments []tokens.Comment, continuation int) {
+	if !p.claim(comments) {
+		return
+	}
+
+	p.indent += continuation
+	for _, comment := range comments {
+		text := p.out.String()
+		trimmed := strings.TrimRight(text, " ")
+
+		if comment.Newlines > 0 && !strings.HasSuffix(trimmed, "\n") {
+			p.out.Truncate(len(trimmed))
+			p.newline()
+		} else if text != "" && !strings.HasSuffix(text, " ") && !strings.HasSuffix(text, "(") && !strings.HasSuffix(text, "[") {
+			p.write(" ")
+		}
+
+		p.write(comment.Value)
+
+		if strings.HasPrefix(comment.Value, "/*") {
+			p.write(" ")
+		} else {
+			p.newline()
+		}
+	}
+	p.indent -= continuation
+}
+
+// statements writes a list of statements together with the comments
+// attached to them. end is the token closing the list, which holds the
+// comments after the last statement. nested is set for block bodies, whose
+// first line follows the opening brace.
+func (p *printer) statements(statements []ast.Statement, end tokens.Token, nested bool) {
+	for _, statement := range statements {
+		p.claim(ast.FirstToken(statement).Comments)
+	}
+
+	rendered := make([]string, len(statements))
+	for idx, statement := range statements {
+		sub := p.sub()
+		sub.statement(statement)
+		rendered[idx] = sub.out.String()
+	}
+
+	l := &lines{printer: p, atStart: !nested, first: true}
+
+	for idx, statement := range statements {
+		start := ast.FirstToken(statement)
+		l.comments(start.Comments)
+		l.separate(start.Newlines)
+		p.write(rendered[idx])
+
+		if idx+1 < len(rendered) && continues(rendered[idx+1]) {
+			p.write(";")
+		}
+	}
+
+	l.comments(end.Comments)
+}
+
+// lines tracks where a list of statements is so that blank lines are only
+// kept between entries and comments sharing a line with the previous entry
+// stay on it.
+type lines struct {
+	printer *printer
+	atStart bool
+	first   bool
+}
+
+func (l *lines) separate(newlines int) {
+	if !l.first && newlines > 1 {
+		l.printer.write("\n")
+	}
+
+	if !l.atStart {
+		l.printer.newline()
+	}
+
+	l.atStart = false
+	l.first = false
+}
+
+func (l *lines) comments(comments []tokens.Comment) {
+	l.printer.claim(comments)
+
+	for idx, comment := range comments {
+		if idx == 0 && comment.Newlines == 0 && !l.atStart {
+			l.printer.write(" ", comment.Value)
+			continue
+		}
+
+		l.separate(comment.Newlines)
+		l.printer.write(comment.Value)
+	}
+}
+
+// continues reports whether a statement starting like source would be
+// parsed as part of the expression before it if no semicolon separated them.
+func continues(source string) bool {
+	return strings.HasPrefix(source, "(") || strings.HasPrefix(source, "[") || strings.HasPrefix(source, "-")
+}
+
+func hasComments(elements []ast.Expression) bool {
+	for _, element := range elements {
+		if len(ast.FirstToken(element).Comments) != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *printer) statement(statement ast.Statement) {
+	switch statement := statement.(type) {
+	case *ast.VariableStatement:
+		p.write(statement.Token.Value, " ")
+		if statement.Pattern != nil {
+			p.expression(statement.Pattern, parser.LOWEST)
+		} else {
+			p.write(statement.Name.Value)
+		}
+		p.write(" = ")
+		p.expression(statement.Value, parser.LOWEST)
+	case *ast.ReturnStatement:
+		p.write("return")
+		if statement.ReturnValue != nil {
+			p.write(" ")
+			p.expression(statement.ReturnValue, parser.LOWEST)
+		}
+	case *ast.YieldStatement:
+		p.write("yield ")
+		p.expression(statement.Value, parser.LOWEST)
+	case *ast.FunctionStatement:
+		p.expression(statement.Function, parser.LOWEST)
+	case *ast.StructStatement:
+		p.write("struct ", statement.Name.Value, " ")
+		p.block(statement.Body)
+	case *ast.AssignStatement:
+		p.expression(statement.Target, parser.LOWEST)
+		p.write(" = ")
+		p.expression(statement.Value, parser.LOWEST)
+	case *ast.ExpressionStatement:
+		p.expression(statement.Expression, parser.LOWEST)
+	case *ast.BlockStatement:
+		p.block(statement)
+	}
+}
+
+func (p *printer) block(block *ast.BlockStatement) {
+	if len(block.Statements) == 0 && len(block.End.Comments) == 0 {
+		p.write("{}")
+		return
+	}
+
+	p.write("{")
+	p.indent++
+	p.statements(block.Statements, block.End, true)
+	p.indent--
+	p.newline()
+	p.write("}")
+}
+
+// arms writes the arms of a match one per line. Expression bodies are
+// followed by a comma, block bodies are not.
+func (p *printer) arms(match *ast.MatchExpression) {
+	if len(match.Arms) == 0 && len(match.End.Comments) == 0 {
+		p.write("{}")
+		return
+	}
+
+	p.write("{")
+	p.indent++
+	l := &lines{printer: p, first: true}
+	for _, arm := range match.Arms {
+		start := ast.FirstToken(arm.Pattern)
+		l.comments(start.Comments)
+		l.separate(start.Newlines)
+
+		p.expression(arm.Pattern, parser.LOWEST)
+		p.write(" => ")
+
+		if block, ok := arm.Body.(*ast.BlockStatement); ok {
+			p.block(block)
+		} else {
+			p.statement(arm.Body)
+			p.write(",")
+		}
+	}
+	l.comments(match.End.Comments)
+	p.indent--
+	p.newline()
+	p.write("}")
+}
+
+func precedence(expression ast.Expression) int {
+	switch expression := expression.(type) {
+	case *ast.InfixExpression:
+		if precedence, ok := operatorPrecedences[expression.Operator]; ok {
+			return precedence
+		}
+
+		return parser.LOWEST
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression, *ast.IndexExpression, *ast.MemberExpression:
+		return parser.CALL
+	}
+
+	return primary
+}
+
+// expression writes expression, wrapping it in parentheses when it binds
+// looser than min requires.
+func (p *printer) expression(expression ast.Expression, min int) {
+	p.inline(ast.FirstToken(expression).Comments, 1)
+
+	if precedence(expression) < min {
+		p.write("(")
+		defer p.write(")")
+	}
+
+	switch expression := expression.(type) {
+	case *ast.Identifier:
+		p.write(expression.Value)
+	case *ast.IntegerLiteral:
+		p.write(expression.Token.Value)
+	case *ast.Boolean:
+		p.write(expression.Token.Value)
+	case *ast.StringLiteral:
+		if expression.Token.TokenType == tokens.RawString {
+			p.write("`", expression.Value, "`")
+		} else {
+			p.write(`"`, escape(expression.Value, ""), `"`)
+		}
+	case *ast.TemplateLiteral:
+		p.write(`"`)
+		for idx, part := range expression.Parts {
+			if !expression.IsLiteral(part) {
+				p.write("${")
+				p.expression(part, parser.LOWEST)
+				p.write("}")
+				continue
+			}
+
+			next := ""
+			if idx+1 < len(expression.Parts) && expression.IsLiteral(expression.Parts[idx+1]) {
+				next = expression.Parts[idx+1].(*ast.StringLiteral).Value
+			}
+
+			p.write(escape(part.(*ast.StringLiteral).Value, next))
+		}
+		p.write(`"`)
+	case *ast.PrefixExpression:
+		p.write(expression.Operator)
+		p.expression(expression.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		precedence := precedence(expression)
+		p.expression(expression.Left, precedence)
+		p.write(" ", expression.Operator, " ")
+		p.expression(expression.Right, precedence+1)
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expression(expression.Condition, parser.LOWEST)
+		p.write(") ")
+		p.block(expression.Consequence)
+
+		if expression.Alternative != nil {
+			p.write(" ")
+			p.inline(expression.Else.Comments, 0)
+			p.write("else ")
+		}
+
+		if expression.IsElseIf() {
+			p.statement(expression.Alternative.Statements[0])
+		} else if expression.Alternative != nil {
+			p.block(expression.Alternative)
+		}
+	case *ast.MatchExpression:
+		p.write("match (")
+		p.expression(expression.Value, parser.LOWEST)
+		p.write(") ")
+		p.arms(expression)
+	case *ast.WhileLiteral:
+		p.write("while (")
+		p.expression(expression.Condition, parser.LOWEST)
+		p.write(") ")
+		p.block(expression.Body)
+	case *ast.ForLiteral:
+		p.write("for (")
+		p.expression(expression.Pattern, parser.LOWEST)
+		p.write(" in ")
+		p.expression(expression.Iterable, parser.LOWEST)
+		p.write(") ")
+		p.block(expression.Body)
+	case *ast.FunctionLiteral:
+		p.write("func")
+		if expression.Name != nil {
+			p.write(" ", expression.Name.Value)
+		}
+		p.write("(")
+		for idx, parameter := range expression.Parameters {
+			if idx > 0 {
+				p.write(", ")
+			}
+
+			if idx < len(expression.Patterns) && expression.Patterns[idx] != nil {
+				p.expression(expression.Patterns[idx], parser.LOWEST)
+			} else {
+				p.inline(parameter.Token.Comments, 1)
+				p.write(parameter.Value)
+			}
+
+			if idx < len(expression.Defaults) && expression.Defaults[idx] != nil {
+				p.write(" = ")
+				p.expression(expression.Defaults[idx], parser.LOWEST)
+			}
+		}
+		if expression.Rest != nil {
+			if len(expression.Parameters) > 0 {
+				p.write(", ")
+			}
+
+			p.write("...", expression.Rest.Value)
+		}
+		p.write(") ")
+		p.block(expression.Body)
+	case *ast.SpreadExpression:
+		p.write("...")
+		p.expression(expression.Value, parser.LOWEST)
+	case *ast.CallExpression:
+		p.expression(expression.Function, parser.CALL)
+		p.write("(")
+		for idx, argument := range expression.Arguments {
+			if idx > 0 {
+				p.write(", ")
+			}
+
+			p.expression(argument, parser.LOWEST)
+		}
+		p.write(")")
+	case *ast.IndexExpression:
+		p.expression(expression.Left, parser.CALL)
+		p.write("[")
+		p.expression(expression.Index, parser.LOWEST)
+		p.write("]")
+	case *ast.MemberExpression:
+		p.expression(expression.Object, parser.CALL)
+		p.write(".", expression.Member.Value)
+	case *ast.ArrayLiteral:
+		p.list("[", "]", expression.Elements, func(p *printer, idx int) {
+			p.expression(expression.Elements[idx], parser.LOWEST)
+		})
+	case *ast.SetLiteral:
+		p.list("#{", "}", expression.Elements, func(p *printer, idx int) {
+			p.expression(expression.Elements[idx], parser.LOWEST)
+		})
+	case *ast.HashLiteral:
+		p.list("{", "}", expression.Keys, func(p *printer, idx int) {
+			key := expression.Keys[idx]
+			if expression.IsShorthand(key) {
+				p.expression(expression.Pairs[key], parser.LOWEST)
+				return
+			}
+
+			p.expression(key, parser.LOWEST)
+			p.write(": ")
+			p.expression(expression.Pairs[key], parser.LOWEST)
+		})
+	}
+}
+
+// escape is the inverse of lexer.Unescape, with control characters other
+// than newlines and tabs written as \u{...}. next is the text written right
+// after value, which decides whether a trailing $ starts an interpolation.
+func escape(value string, next string) string {
+	var out strings.Builder
+
+	for idx, r := range value {
+		switch {
+		case r == '\n':
+			out.WriteString(`\n`)
+		case r == '\t':
+			out.WriteString(`\t`)
+		case r == '\r':
+			out.WriteString(`\r`)
+		case r == '\\' || r == '"':
+			out.WriteString(`\` + string(r))
+		case r == '$' && strings.HasPrefix(value[idx+1:]+next, "{"):
+			out.WriteString(`\$`)
+		case unicode.IsControl(r):
+			fmt.Fprintf(&out, `\u{%x}`, r)
+		default:
+			out.WriteRune(r)
+		}
+	}
+
+	return out.String()
+}
+
+// list writes the elements of an array or hash literal on one line if they
+// fit, none of them spans several lines and the source did not already start
+// them on a new line, and one per line otherwise.
+func (p *printer) list(open, close string, elements []ast.Expression, element func(p *printer, idx int)) {
+	length := len(elements)
+	if length == 0 {
+		p.write(open, close)
+		return
+	}
+
+	line := p.sub()
+	for idx := 0; idx < length; idx++ {
+		if idx > 0 {
+			line.write(", ")
+		}
+
+		element(line, idx)
+	}
+
+	rendered := line.out.String()
+	fits := !strings.Contains(rendered, "\n") && utf8.RuneCountInString(rendered)+p.indent*len(indentation) <= maxWidth
+	if fits && ast.FirstToken(elements[0]).Newlines == 0 && !hasComments(elements) {
+		p.write(open, rendered, close)
+		return
+	}
+
+	p.write(open)
+	p.indent++
+	l := &lines{printer: p, first: true}
+	for idx := 0; idx < length; idx++ {
+		l.comments(ast.FirstToken(elements[idx]).Comments)
+		l.separate(0)
+		element(p, idx)
+
+		if idx+1 < length {
+			p.write(",")
+		}
+	}
+	p.indent--
+	p.newline()
+	p.write(close)
+}
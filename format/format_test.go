@@ -0,0 +1,198 @@
+package format
+
+import (
+	"bytes"
+	"github.com/kanersps/loop/ast"
+	"github.com/kanersps/loop/parser"
+	"github.com/kanersps/loop/parser/lexer"
+	"github.com/kanersps/loop/parser/tokens"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"var  a=1;a", "var a = 1\na\n"},
+		{"a  is  b==c", "a is b == c\n"},
+		{"a||b&&c; (a||b)&&c; a==b&&!c", "a || b && c;\n(a || b) && c\na == b && !c\n"},
+		{"(1 + 2) * 3; 1 + (2 * 3); 1 - (2 - 3); (1 - 2) - 3", "(1 + 2) * 3\n1 + 2 * 3\n1 - (2 - 3)\n1 - 2 - 3\n"},
+		{"-(1 + 2); (-a)[0]; f()[0]; !(a == b)", "-(1 + 2);\n(-a)[0]\nf()[0]\n!(a == b)\n"},
+		{"var a = 1; (a)", "var a = 1\na\n"},
+		{"var a = b; (c + a)[0]", "var a = b;\n(c + a)[0]\n"},
+		{"var a = 1\n\n\n\nvar b = 2", "var a = 1\n\nvar b = 2\n"},
+		{"if(a){b}else{c}", "if (a) {\n    b\n} else {\n    c\n}\n"},
+		{"if(a){b}else if(c){d}else{e}", "if (a) {\n    b\n} else if (c) {\n    d\n} else {\n    e\n}\n"},
+		{"match(x){1=>\"one\",[a,...b]=>{a}\n\n{\"t\":t}=>t}", "match (x) {\n    1 => \"one\",\n    [a, ...b] => {\n        a\n    }\n\n    {\"t\": t} => t,\n}\n"},
+		{"var [a,...b]=xs; var {name,\"k\":[c]}=h; func([x],{y}={}){}", "var [a, ...b] = xs\nvar {name, \"k\": [c]} = h\nfunc([x], {y} = {}) {}\n"},
+		{"const  a=1; const [b]=c", "const a = 1\nconst [b] = c\n"},
+		{"match(x){}", "match (x) {}\n"},
+		{"func g(){for([k,v]in h){yield k}}", "func g() {\n    for ([k, v] in h) {\n        yield k\n    }\n}\n"},
+		{"struct P{var x=0\nfunc init(x){self.x=x}}; P(1).x; (a+b).c", "struct P {\n    var x = 0\n    func init(x) {\n        self.x = x\n    }\n}\nP(1).x;\n(a + b).c\n"},
+		{"while(true){}", "while (true) {}\n"},
+		{"var f = func(a,b){return a+b;};", "var f = func(a, b) {\n    return a + b\n}\n"},
+		{"var f = func(a,b=1+2,...rest){}; f(...xs,1)", "var f = func(a, b = 1 + 2, ...rest) {}\nf(...xs, 1)\n"},
+		{"func(...rest){}", "func(...rest) {}\n"},
+		{"func  add(a,b){a+b};var g=func h(){}", "func add(a, b) {\n    a + b\n}\nvar g = func h() {}\n"},
+		{"#{1,2}; #{}; x  in  #{...xs}; [...xs,1]", "#{1, 2}\n#{}\nx in #{...xs};\n[...xs, 1]\n"},
+		{`{"a":1,"b":[1,2]}`, "{\"a\": 1, \"b\": [1, 2]}\n"},
+		{"{\n\"a\": 1, \"b\": 2}", "{\n    \"a\": 1,\n    \"b\": 2\n}\n"},
+		{"\"a\\tb\\u{1}\\\\\\\"\\${x}\"", "\"a\\tb\\u{1}\\\\\\\"\\${x}\"\n"},
+		{"\"line\nbreak\"", "\"line\\nbreak\"\n"},
+		{"`raw\n\\n`", "`raw\n\\n`\n"},
+		{`"Hi ${name + "!"}${ 1+2 }"`, "\"Hi ${name + \"!\"}${1 + 2}\"\n"},
+		{`var y = 5; "x${"$"}{y}"`, "var y = 5\n\"x${\"$\"}{y}\"\n"},
+		{`"a${ "b" }c"`, "\"a${\"b\"}c\"\n"},
+		{`{"f": func() { 1 }}`, "{\n    \"f\": func() {\n        1\n    }\n}\n"},
+	}
+
+	for _, tc := range tests {
+		formatted, err := Source([]byte(tc.input))
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", tc.input, err)
+			continue
+		}
+
+		if string(formatted) != tc.expected {
+			t.Errorf("Source(%q) is wrong.\nexpected=%q\ngot=%q", tc.input, tc.expected, string(formatted))
+		}
+	}
+
+	commented := `#!/usr/bin/env loop
+// leading
+var add = func(a, b) { // on brace
+    return a + b // trailing
+    // before close
+}
+
+
+/* block */
+var config = {
+    // first
+    "a": 1, // trailing pair
+    "b": 2
+}
+// end
+`
+	formatted, err := Source([]byte(commented))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := strings.Replace(commented, "\n\n\n", "\n\n", 1)
+	if string(formatted) != expected {
+		t.Errorf("comments were not preserved.\nexpected=%q\ngot=%q", expected, string(formatted))
+	}
+
+	if _, err := Source([]byte("var = 1")); err == nil {
+		t.Errorf("Source should fail on invalid input")
+	}
+}
+
+func TestSource_Comments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"f(1, /* x */ 2)", "f(1, /* x */ 2)\n"},
+		{"f(1,\n// x\n2)", "f(1,\n    // x\n    2)\n"},
+		{"var x = 1 + // plus\n2", "var x = 1 + // plus\n    2\n"},
+		{"if (a) { b } // t\nelse { c }", "if (a) {\n    b\n} // t\nelse {\n    c\n}\n"},
+		{"if (a) { b } /* t */ else if (c) { d }", "if (a) {\n    b\n} /* t */ else if (c) {\n    d\n}\n"},
+		{"func(a, /* b */ b) {}", "func(a, /* b */ b) {}\n"},
+	}
+
+	for _, tc := range tests {
+		formatted, err := Source([]byte(tc.input))
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", tc.input, err)
+			continue
+		}
+
+		if string(formatted) != tc.expected {
+			t.Errorf("Source(%q) is wrong.\nexpected=%q\ngot=%q", tc.input, tc.expected, string(formatted))
+		}
+	}
+
+	// These comments are attached to tokens the syntax tree does not keep.
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2 /* two */, 3]", "1:7: cannot format the comment /* two */ at this position"},
+		{"if (a /* c */) { b }", "1:7: cannot format the comment /* c */ at this position"},
+	}
+
+	for _, tt := range errorTests {
+		_, err := Source([]byte(tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("Source(%q): expected error %q. got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestFprint_Template(t *testing.T) {
+	text := func(value string) ast.Expression {
+		return &ast.StringLiteral{Token: tokens.Token{TokenType: tokens.Template, Value: value}, Value: value}
+	}
+
+	template := &ast.TemplateLiteral{Parts: []ast.Expression{text("x$"), text("{y}")}}
+
+	var out bytes.Buffer
+	if err := Fprint(&out, template); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != `"x\${y}"` {
+		t.Errorf("a $ before { in the next part should be escaped. got=%q", out.String())
+	}
+}
+
+func TestSource_Examples(t *testing.T) {
+	paths, err := filepath.Glob("../examples/*.loop")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no examples found: %v", err)
+	}
+
+	for _, path := range paths {
+		input, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		once, err := Source(input)
+		if err != nil {
+			t.Errorf("%s: %s", path, err)
+			continue
+		}
+
+		twice, err := Source(once)
+		if err != nil {
+			t.Errorf("%s: formatted output does not parse: %s", path, err)
+			continue
+		}
+
+		if string(once) != string(twice) {
+			t.Errorf("%s: formatting is not idempotent.\nfirst=%q\nsecond=%q", path, once, twice)
+		}
+
+		if parse(t, string(input)) != parse(t, string(once)) {
+			t.Errorf("%s: formatting changed the program", path)
+		}
+	}
+}
+
+func parse(t *testing.T, input string) string {
+	p := parser.Create(lexer.Create(input))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return program.String()
+}
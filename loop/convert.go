@@ -0,0 +1,100 @@
+package loop
+
+import (
+	"fmt"
+	"github.com/kanersps/loop/models"
+	"reflect"
+)
+
+// ToObject converts a Go value to its Loop counterpart. Values that already
+// are models.Object are passed through unchanged; slices, maps, structs and
+// functions are converted with reflection.
+func ToObject(value interface{}) (models.Object, error) {
+	switch value := value.(type) {
+	case nil:
+		return models.NULL, nil
+	case models.Object:
+		return value, nil
+	case bool:
+		if value {
+			return models.TRUE, nil
+		}
+		return models.FALSE, nil
+	case int:
+		return &models.Integer{Value: int64(value)}, nil
+	case int64:
+		return &models.Integer{Value: value}, nil
+	case string:
+		return &models.String{Value: value}, nil
+	}
+
+	return fromValue(reflect.ValueOf(value))
+}
+
+// FromObject converts a Loop value to Go. Integers become int64, arrays
+// []interface{}, hashes map[interface{}]interface{} and sets
+// map[interface{}]bool; functions and other values without a Go counterpart
+// are returned as the models.Object itself. Array keys and members stay
+// models.Objects since slices cannot be map keys.
+func FromObject(obj models.Object) interface{} {
+	switch obj := obj.(type) {
+	case nil, *models.Null:
+		return nil
+	case *models.Integer:
+		return obj.Value
+	case *models.Boolean:
+		return obj.Value
+	case *models.String:
+		return obj.Value
+	case *models.Array:
+		elements := make([]interface{}, len(obj.Elements))
+		for idx, element := range obj.Elements {
+			elements[idx] = FromObject(element)
+		}
+
+		return elements
+	case *models.Hash:
+		pairs := make(map[interface{}]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			pairs[fromKey(pair.Key)] = FromObject(pair.Value)
+		}
+
+		return pairs
+	case *models.Set:
+		members := make(map[interface{}]bool, len(obj.Elements))
+		for _, element := range obj.Elements {
+			members[fromKey(element)] = true
+		}
+
+		return members
+	}
+
+	return obj
+}
+
+func fromKey(key models.Object) interface{} {
+	if _, ok := key.(*models.Array); ok {
+		return key
+	}
+
+	return FromObject(key)
+}
+
+func setPair(hash *models.Hash, key, value interface{}) error {
+	keyObj, err := ToObject(key)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := models.HashKeyOf(keyObj); !ok {
+		return fmt.Errorf("loop: %s cannot be used as a hash key", keyObj.Type())
+	}
+
+	valueObj, err := ToObject(value)
+	if err != nil {
+		return err
+	}
+
+	hash.Set(keyObj, valueObj)
+	return nil
+}
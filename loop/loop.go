@@ -0,0 +1,197 @@
+package loop
+
+import (
+	"fmt"
+	"github.com/kanersps/loop/evaluator"
+	"github.com/kanersps/loop/models"
+	"github.com/kanersps/loop/object"
+	"github.com/kanersps/loop/object/builtins"
+	"github.com/kanersps/loop/parser"
+	"github.com/kanersps/loop/parser/lexer"
+	"io"
+	"strings"
+)
+
+// Interpreter runs Loop source against a single global environment, so
+// bindings made by one Run are visible to the next.
+type Interpreter struct {
+	env *models.Environment
+}
+
+// ParseError holds every error the parser reported for a program.
+type ParseError struct {
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	return "parse error: " + strings.Join(e.Errors, "; ")
+}
+
+// RuntimeError wraps an error object produced while evaluating a program.
+type RuntimeError struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Message
+}
+
+// New returns an interpreter with an empty global environment that uses the
+// process's standard streams.
+func New() *Interpreter {
+	return &Interpreter{env: object.NewEnvironment()}
+}
+
+// SetStdin sets the reader used by input and readline.
+func (i *Interpreter) SetStdin(in io.Reader) {
+	i.env.Streams = models.NewStreams(in, i.env.IO().Out, i.env.IO().Err)
+}
+
+// SetStdout sets the writer used by print and println.
+func (i *Interpreter) SetStdout(out io.Writer) {
+	i.env.Streams = &models.Streams{In: i.env.IO().In, Out: out, Err: i.env.IO().Err}
+}
+
+// SetStderr sets the writer used by eprint and eprintln.
+func (i *Interpreter) SetStderr(err io.Writer) {
+	i.env.Streams = &models.Streams{In: i.env.IO().In, Out: i.env.IO().Out, Err: err}
+}
+
+// SetStrict makes if, while, !, && and || reject conditions that are not
+// booleans.
+func (i *Interpreter) SetStrict(strict bool) {
+	i.env.Strict = strict
+}
+
+// SetAllowShadowing lets scripts declare top-level variables, constants,
+// functions and structs named after builtins. Names declared inside
+// functions may always reuse them.
+func (i *Interpreter) SetAllowShadowing(allow bool) {
+	i.env.AllowShadowing = allow
+}
+
+// Env exposes the global environment for callers that want to work with
+// models.Object values directly.
+func (i *Interpreter) Env() *models.Environment {
+	return i.env
+}
+
+// Run evaluates source and converts the value of the last statement to Go.
+func (i *Interpreter) Run(source string) (interface{}, error) {
+	result, err := i.Eval(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return FromObject(result), nil
+}
+
+// Eval evaluates source and returns the raw result object.
+func (i *Interpreter) Eval(source string) (models.Object, error) {
+	l := lexer.Create(source)
+	p := parser.Create(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Errors: p.Errors()}
+	}
+
+	return evaluate(func() models.Object { return evaluator.Eval(program, i.env) })
+}
+
+// Call invokes the Loop function bound to name with args converted to Loop
+// values, and converts its return value back to Go.
+func (i *Interpreter) Call(name string, args ...interface{}) (interface{}, error) {
+	fn, ok := i.lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("loop: %s is not defined", name)
+	}
+
+	if fn.Type() != models.FUNCTION && fn.Type() != models.BUILTIN {
+		return nil, fmt.Errorf("loop: %s is not a function. got=%s", name, fn.Type())
+	}
+
+	objects := make([]models.Object, len(args))
+	for idx, arg := range args {
+		obj, err := ToObject(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		objects[idx] = obj
+	}
+
+	result, err := evaluate(func() models.Object { return evaluator.ApplyFunction(fn, objects, i.env) })
+	if err != nil {
+		return nil, err
+	}
+
+	return FromObject(result), nil
+}
+
+// Set binds a Go value to a global name. Names the script declared const
+// cannot be set.
+func (i *Interpreter) Set(name string, value interface{}) error {
+	if _, ok := i.env.Constant(name); ok {
+		return fmt.Errorf("loop: cannot reassign constant %s", name)
+	}
+
+	obj, err := ToObject(value)
+	if err != nil {
+		return err
+	}
+
+	i.env.Set(name, obj)
+	return nil
+}
+
+// Get returns the Go value bound to a global name.
+func (i *Interpreter) Get(name string) (interface{}, bool) {
+	obj, ok := i.lookup(name)
+	if !ok {
+		return nil, false
+	}
+
+	return FromObject(obj), true
+}
+
+// RegisterBuiltin makes fn callable from scripts run by this interpreter
+// only; the shared builtins.Functions table is left untouched.
+func (i *Interpreter) RegisterBuiltin(name string, fn models.BuiltinFunction) {
+	i.env.Set(name, &models.Builtin{Func: fn, Env: i.env})
+}
+
+func (i *Interpreter) lookup(name string) (models.Object, bool) {
+	if obj, ok := i.env.Get(name); ok {
+		return obj, true
+	}
+
+	if builtin, ok := builtins.Functions[name]; ok {
+		return builtin, true
+	}
+
+	return nil, false
+}
+
+// evaluate runs fn and converts its result with toResult. A panic, such as
+// one raised by a Go function registered with the interpreter, is returned as
+// a RuntimeError instead of unwinding through the caller.
+func evaluate(fn func() models.Object) (result models.Object, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, &RuntimeError{Message: fmt.Sprint("PANIC: ", r)}
+		}
+	}()
+
+	return toResult(fn())
+}
+
+func toResult(result models.Object) (models.Object, error) {
+	if err, ok := result.(*models.Error); ok {
+		return nil, &RuntimeError{Message: err.Message, Line: err.Line, Column: err.Column}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,241 @@
+package loop
+
+import (
+	"bytes"
+	"github.com/kanersps/loop/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInterpreter_Run(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"5 + 5", int64(10)},
+		{`"Hello" + " " + "World"`, "Hello World"},
+		{"1 < 2", true},
+		{"[1, 2, \"three\"]", []interface{}{int64(1), int64(2), "three"}},
+		{`{"one": 1}`, map[interface{}]interface{}{"one": int64(1)}},
+		{"var test = 5;", nil},
+	}
+
+	for _, tc := range tests {
+		result, err := New().Run(tc.input)
+		if err != nil {
+			t.Errorf("Run(%q) returned error: %s", tc.input, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(result, tc.expected) {
+			t.Errorf("Run(%q) returned wrong value. expected=%#v. got=%#v", tc.input, tc.expected, result)
+		}
+	}
+}
+
+func TestInterpreter_RunErrors(t *testing.T) {
+	_, err := New().Run("var = 5")
+	if _, ok := err.(*ParseError); !ok {
+		t.Errorf("expected *ParseError. got=%T (%v)", err, err)
+	}
+
+	_, err = New().Run("unknown")
+	runtimeErr, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("expected *RuntimeError. got=%T (%v)", err, err)
+	}
+
+	if runtimeErr.Message != "UNKNOWN-IDENTIFIER: unknown" {
+		t.Errorf("wrong error message. got=%q", runtimeErr.Message)
+	}
+
+	interpreter := New()
+	interpreter.RegisterFunc("boom", func() { panic("boom") })
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 / 0", "DIVISION BY ZERO"},
+		{"boom()", "PANIC: boom"},
+	}
+
+	for _, tc := range tests {
+		_, err := interpreter.Run(tc.input)
+		if err == nil || err.Error() != tc.expected {
+			t.Errorf("Run(%q) returned wrong error. expected=%q. got=%v", tc.input, tc.expected, err)
+		}
+	}
+
+	if _, err := interpreter.Call("boom"); err == nil || err.Error() != "PANIC: boom" {
+		t.Errorf("Call should return a panic as an error. got=%v", err)
+	}
+}
+
+func TestInterpreter_PersistentState(t *testing.T) {
+	interpreter := New()
+
+	if _, err := interpreter.Run("var counter = 41;"); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := interpreter.Run("counter + 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != int64(42) {
+		t.Errorf("state was not kept between runs. got=%#v", result)
+	}
+}
+
+func TestInterpreter_Call(t *testing.T) {
+	interpreter := New()
+
+	if _, err := interpreter.Run("var add = func(a, b) { return a + b; };"); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := interpreter.Call("add", 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != int64(5) {
+		t.Errorf("add(2, 3) returned wrong value. got=%#v", result)
+	}
+
+	result, err = interpreter.Call("len", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != int64(4) {
+		t.Errorf("len(\"test\") returned wrong value. got=%#v", result)
+	}
+
+	if _, err := interpreter.Call("missing"); err == nil {
+		t.Errorf("calling an undefined function should return an error")
+	}
+
+	interpreter.Set("notAFunction", 5)
+	if _, err := interpreter.Call("notAFunction"); err == nil {
+		t.Errorf("calling a non-function should return an error")
+	}
+}
+
+func TestInterpreter_SetGet(t *testing.T) {
+	interpreter := New()
+
+	if err := interpreter.Set("names", []interface{}{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := interpreter.Run("len(names)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != int64(2) {
+		t.Errorf("len(names) returned wrong value. got=%#v", result)
+	}
+
+	value, ok := interpreter.Get("names")
+	if !ok {
+		t.Fatalf("names is not defined")
+	}
+
+	if !reflect.DeepEqual(value, []interface{}{"a", "b"}) {
+		t.Errorf("Get returned wrong value. got=%#v", value)
+	}
+
+	if _, ok := interpreter.Get("missing"); ok {
+		t.Errorf("Get should not find undefined names")
+	}
+
+	if err := interpreter.Set("channel", make(chan int)); err == nil {
+		t.Errorf("setting an unsupported type should return an error")
+	}
+
+	if _, err := interpreter.Run("const limit = 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := interpreter.Set("limit", 2); err == nil || err.Error() != "loop: cannot reassign constant limit" {
+		t.Errorf("setting a constant should return an error. got=%v", err)
+	}
+}
+
+func TestInterpreter_RegisterBuiltin(t *testing.T) {
+	interpreter := New()
+
+	interpreter.RegisterBuiltin("double", func(env *models.Environment, args ...models.Object) models.Object {
+		return &models.Integer{Value: args[0].(*models.Integer).Value * 2}
+	})
+
+	result, err := interpreter.Run("double(21)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != int64(42) {
+		t.Errorf("double(21) returned wrong value. got=%#v", result)
+	}
+
+	if _, err := New().Run("double(21)"); err == nil {
+		t.Errorf("builtins registered on one interpreter should not leak into another")
+	}
+}
+
+func TestInterpreter_Streams(t *testing.T) {
+	var out, errOut bytes.Buffer
+
+	interpreter := New()
+	interpreter.SetStdin(strings.NewReader("world\n"))
+	interpreter.SetStdout(&out)
+	interpreter.SetStderr(&errOut)
+
+	if _, err := interpreter.Run(`println("hello " + readline()); eprint("oops")`); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != "hello world\n" {
+		t.Errorf("wrong output written. got=%q", out.String())
+	}
+
+	if errOut.String() != "oops" {
+		t.Errorf("wrong error output written. got=%q", errOut.String())
+	}
+}
+
+func TestInterpreter_Strict(t *testing.T) {
+	interpreter := New()
+
+	if _, err := interpreter.Run("if (1) { 2 }"); err != nil {
+		t.Fatalf("non-strict interpreters should accept integer conditions. got=%s", err)
+	}
+
+	interpreter.SetStrict(true)
+
+	_, err := interpreter.Run("if (1) { 2 }")
+	if err == nil || err.Error() != "NON-BOOLEAN CONDITION: INTEGER" {
+		t.Errorf("strict interpreters should reject integer conditions. got=%v", err)
+	}
+}
+
+func TestInterpreter_AllowShadowing(t *testing.T) {
+	interpreter := New()
+
+	_, err := interpreter.Run("var len = 5")
+	if err == nil || err.Error() != "CANNOT SHADOW BUILT-IN FUNCTION `len`" {
+		t.Errorf("interpreters should reject shadowing builtins by default. got=%v", err)
+	}
+
+	interpreter.SetAllowShadowing(true)
+
+	result, err := interpreter.Run("var len = 5; len")
+	if err != nil || result != int64(5) {
+		t.Errorf("shadowing should be allowed. got=%v, %v", result, err)
+	}
+}
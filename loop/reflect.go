@@ -0,0 +1,505 @@
+package loop
+
+import (
+	"fmt"
+	"github.com/kanersps/loop/evaluator"
+	"github.com/kanersps/loop/models"
+	"reflect"
+)
+
+var (
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	objectType = reflect.TypeOf((*models.Object)(nil)).Elem()
+)
+
+// RegisterFunc exposes any Go function to scripts. Arguments and return
+// values are converted with reflection; a non-nil error result is turned into
+// a Loop error and multiple results are returned as an array.
+//
+// A Loop function passed for a func parameter reports a failure through the
+// func's trailing error result. Without one it returns zero values instead,
+// and a failure while fn is still running becomes the error of the call.
+func (i *Interpreter) RegisterFunc(name string, fn interface{}) error {
+	builtin, err := wrapFunc(name, reflect.ValueOf(fn))
+	if err != nil {
+		return err
+	}
+
+	builtin.Env = i.env
+	i.env.Set(name, builtin)
+	return nil
+}
+
+// RegisterStruct binds a struct, or pointer to struct, as a hash holding its
+// exported fields and methods. Fields can be renamed or hidden with a
+// `loop:"name"` or `loop:"-"` tag, and are re-read after every method call so
+// the hash reflects changes made through pointer receivers.
+func (i *Interpreter) RegisterStruct(name string, value interface{}) error {
+	v := reflect.ValueOf(value)
+	if reflect.Indirect(v).Kind() != reflect.Struct {
+		return fmt.Errorf("loop: %s is not a struct. got=%T", name, value)
+	}
+
+	hash, err := structToHash(v)
+	if err != nil {
+		return err
+	}
+
+	i.env.Set(name, hash)
+	return nil
+}
+
+func wrapFunc(name string, fn reflect.Value) (*models.Builtin, error) {
+	if fn.Kind() != reflect.Func || fn.IsNil() {
+		return nil, fmt.Errorf("loop: %s is not a function", name)
+	}
+
+	return &models.Builtin{
+		Func: func(env *models.Environment, args ...models.Object) models.Object {
+			var failure error
+
+			in, errObj := convertArgs(name, fn.Type(), args, env, &failure)
+			if errObj != nil {
+				return errObj
+			}
+
+			results := fn.Call(in)
+			if failure != nil {
+				return &models.Error{Message: failure.Error()}
+			}
+
+			return convertResults(name, results)
+		},
+	}, nil
+}
+
+func convertArgs(name string, fnType reflect.Type, args []models.Object, env *models.Environment, failure *error) ([]reflect.Value, *models.Error) {
+	numIn := fnType.NumIn()
+
+	if fnType.IsVariadic() {
+		if len(args) < numIn-1 {
+			return nil, &models.Error{Message: fmt.Sprintf("WRONG NUMBER OF ARGUMENTS TO FUNCTION `%s`. expected=at least %d. got=%d", name, numIn-1, len(args))}
+		}
+	} else if len(args) != numIn {
+		return nil, &models.Error{Message: fmt.Sprintf("WRONG NUMBER OF ARGUMENTS TO FUNCTION `%s`. expected=%d. got=%d", name, numIn, len(args))}
+	}
+
+	in := make([]reflect.Value, len(args))
+	for idx, arg := range args {
+		var argType reflect.Type
+		if fnType.IsVariadic() && idx >= numIn-1 {
+			argType = fnType.In(numIn - 1).Elem()
+		} else {
+			argType = fnType.In(idx)
+		}
+
+		value, err := toValue(arg, argType, env, failure)
+		if err != nil {
+			return nil, &models.Error{Message: fmt.Sprintf("ARGUMENT INVALID TYPE TO FUNCTION `%s` (argument %d). %s", name, idx, err)}
+		}
+
+		in[idx] = value
+	}
+
+	return in, nil
+}
+
+func convertResults(name string, results []reflect.Value) models.Object {
+	values := []models.Object{}
+
+	for _, result := range results {
+		if result.Type() == errorType {
+			if !result.IsNil() {
+				return &models.Error{Message: result.Interface().(error).Error()}
+			}
+
+			continue
+		}
+
+		obj, err := fromValue(result)
+		if err != nil {
+			return &models.Error{Message: fmt.Sprintf("INVALID RETURN VALUE FROM FUNCTION `%s`. %s", name, err)}
+		}
+
+		values = append(values, obj)
+	}
+
+	switch len(values) {
+	case 0:
+		return models.NULL
+	case 1:
+		return values[0]
+	default:
+		return &models.Array{Elements: values}
+	}
+}
+
+func fromValue(v reflect.Value) (models.Object, error) {
+	if !v.IsValid() {
+		return models.NULL, nil
+	}
+
+	if v.Type().Implements(objectType) && v.CanInterface() {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return models.NULL, nil
+		}
+
+		return v.Interface().(models.Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return ToObject(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &models.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &models.Integer{Value: int64(v.Uint())}, nil
+	case reflect.String:
+		return &models.String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return models.NULL, nil
+		}
+
+		elements := make([]models.Object, v.Len())
+		for idx := range elements {
+			element, err := fromValue(v.Index(idx))
+			if err != nil {
+				return nil, err
+			}
+
+			elements[idx] = element
+		}
+
+		return &models.Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return models.NULL, nil
+		}
+
+		hash := &models.Hash{Pairs: make(map[models.HashKey]models.HashPair)}
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := fromValue(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := fromValue(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+
+			if err := setPair(hash, key, value); err != nil {
+				return nil, err
+			}
+		}
+
+		return hash, nil
+	case reflect.Struct:
+		return structToHash(v)
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return models.NULL, nil
+		}
+
+		if v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Struct {
+			return structToHash(v)
+		}
+
+		return fromValue(v.Elem())
+	case reflect.Func:
+		if v.IsNil() {
+			return models.NULL, nil
+		}
+
+		return wrapFunc("func", v)
+	}
+
+	return nil, fmt.Errorf("loop: cannot convert %s to a Loop value", v.Type())
+}
+
+// toValue converts obj to a Go value of type t. Loop functions become funcs
+// that record failures they have no error result for in failure.
+func toValue(obj models.Object, t reflect.Type, env *models.Environment, failure *error) (reflect.Value, error) {
+	mismatch := fmt.Errorf("expected=%s. got=%s", t, obj.Type())
+
+	if reflect.TypeOf(obj).AssignableTo(t) && t.Kind() != reflect.Interface || t == objectType {
+		return reflect.ValueOf(obj), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		if t.NumMethod() != 0 {
+			return reflect.Value{}, mismatch
+		}
+
+		value := FromObject(obj)
+		if value == nil {
+			return reflect.Zero(t), nil
+		}
+
+		return reflect.ValueOf(value), nil
+	case reflect.Bool:
+		boolean, ok := obj.(*models.Boolean)
+		if !ok {
+			return reflect.Value{}, mismatch
+		}
+
+		return reflect.ValueOf(boolean.Value).Convert(t), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		integer, ok := obj.(*models.Integer)
+		if !ok {
+			return reflect.Value{}, mismatch
+		}
+
+		value := reflect.New(t).Elem()
+		if value.OverflowInt(integer.Value) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", integer.Value, t)
+		}
+
+		value.SetInt(integer.Value)
+		return value, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		integer, ok := obj.(*models.Integer)
+		if !ok {
+			return reflect.Value{}, mismatch
+		}
+
+		value := reflect.New(t).Elem()
+		if integer.Value < 0 || value.OverflowUint(uint64(integer.Value)) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", integer.Value, t)
+		}
+
+		value.SetUint(uint64(integer.Value))
+		return value, nil
+	case reflect.String:
+		str, ok := obj.(*models.String)
+		if !ok {
+			return reflect.Value{}, mismatch
+		}
+
+		return reflect.ValueOf(str.Value).Convert(t), nil
+	case reflect.Slice:
+		if obj == models.NULL {
+			return reflect.Zero(t), nil
+		}
+
+		array, ok := obj.(*models.Array)
+		if !ok {
+			return reflect.Value{}, mismatch
+		}
+
+		slice := reflect.MakeSlice(t, len(array.Elements), len(array.Elements))
+		for idx, element := range array.Elements {
+			value, err := toValue(element, t.Elem(), env, failure)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+
+			slice.Index(idx).Set(value)
+		}
+
+		return slice, nil
+	case reflect.Map:
+		if obj == models.NULL {
+			return reflect.Zero(t), nil
+		}
+
+		hash, ok := obj.(*models.Hash)
+		if !ok {
+			return reflect.Value{}, mismatch
+		}
+
+		m := reflect.MakeMapWithSize(t, len(hash.Pairs))
+		for _, pair := range hash.Pairs {
+			key, err := toValue(pair.Key, t.Key(), env, failure)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+
+			value, err := toValue(pair.Value, t.Elem(), env, failure)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+
+			m.SetMapIndex(key, value)
+		}
+
+		return m, nil
+	case reflect.Struct:
+		hash, ok := obj.(*models.Hash)
+		if !ok {
+			return reflect.Value{}, mismatch
+		}
+
+		value := reflect.New(t).Elem()
+		for idx := 0; idx < t.NumField(); idx++ {
+			name, ok := fieldName(t.Field(idx))
+			if !ok {
+				continue
+			}
+
+			fieldValue, ok := hash.Get(&models.String{Value: name})
+			if !ok {
+				continue
+			}
+
+			field, err := toValue(fieldValue, t.Field(idx).Type, env, failure)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("field %s: %s", name, err)
+			}
+
+			value.Field(idx).Set(field)
+		}
+
+		return value, nil
+	case reflect.Ptr:
+		if obj == models.NULL {
+			return reflect.Zero(t), nil
+		}
+
+		value, err := toValue(obj, t.Elem(), env, failure)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(value)
+		return ptr, nil
+	case reflect.Func:
+		if obj.Type() != models.FUNCTION && obj.Type() != models.BUILTIN {
+			return reflect.Value{}, mismatch
+		}
+
+		return reflect.MakeFunc(t, func(in []reflect.Value) []reflect.Value {
+			args := make([]models.Object, len(in))
+			for idx, arg := range in {
+				converted, err := fromValue(arg)
+				if err != nil {
+					converted = &models.Error{Message: err.Error()}
+				}
+
+				args[idx] = converted
+			}
+
+			return funcResults(t, evaluator.ApplyFunction(obj, args, env), env, failure)
+		}), nil
+	}
+
+	return reflect.Value{}, mismatch
+}
+
+// funcResults maps the result of calling a Loop function from Go onto the
+// results of the Go signature it is standing in for. A Loop error is reported
+// through a trailing error result when there is one. Otherwise the results
+// are left zero and the first such error is kept in failure.
+func funcResults(t reflect.Type, result models.Object, env *models.Environment, failure *error) []reflect.Value {
+	out := make([]reflect.Value, t.NumOut())
+	for idx := range out {
+		out[idx] = reflect.Zero(t.Out(idx))
+	}
+
+	hasError := t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType
+
+	var err error
+	if errObj, ok := result.(*models.Error); ok {
+		err = &RuntimeError{Message: errObj.Message, Line: errObj.Line, Column: errObj.Column}
+	} else if t.NumOut() > 0 && t.Out(0) != errorType {
+		value, convErr := toValue(result, t.Out(0), env, failure)
+		if convErr != nil {
+			err = fmt.Errorf("loop: invalid return value. %s", convErr)
+		} else {
+			out[0] = value
+		}
+	}
+
+	if err != nil {
+		if !hasError {
+			if *failure == nil {
+				*failure = err
+			}
+
+			return out
+		}
+
+		out[len(out)-1] = reflect.ValueOf(&err).Elem()
+	}
+
+	return out
+}
+
+func structToHash(v reflect.Value) (*models.Hash, error) {
+	if v.Kind() == reflect.Struct {
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		v = ptr
+	}
+
+	hash := &models.Hash{Pairs: make(map[models.HashKey]models.HashPair)}
+	if err := syncFields(hash, v.Elem()); err != nil {
+		return nil, err
+	}
+
+	for idx := 0; idx < v.NumMethod(); idx++ {
+		name := v.Type().Method(idx).Name
+		method, err := wrapFunc(name, v.Method(idx))
+		if err != nil {
+			return nil, err
+		}
+
+		call := method.Func
+		method.Func = func(env *models.Environment, args ...models.Object) models.Object {
+			result := call(env, args...)
+
+			if err := syncFields(hash, v.Elem()); err != nil {
+				return &models.Error{Message: err.Error()}
+			}
+
+			return result
+		}
+
+		if err := setPair(hash, name, method); err != nil {
+			return nil, err
+		}
+	}
+
+	return hash, nil
+}
+
+func syncFields(hash *models.Hash, v reflect.Value) error {
+	for idx := 0; idx < v.NumField(); idx++ {
+		name, ok := fieldName(v.Type().Field(idx))
+		if !ok {
+			continue
+		}
+
+		value, err := fromValue(v.Field(idx))
+		if err != nil {
+			return fmt.Errorf("loop: field %s: %s", name, err)
+		}
+
+		if err := setPair(hash, name, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func fieldName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+
+	tag := field.Tag.Get("loop")
+	if tag == "-" {
+		return "", false
+	}
+
+	if tag != "" {
+		return tag, true
+	}
+
+	return field.Name, true
+}
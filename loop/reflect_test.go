@@ -0,0 +1,168 @@
+package loop
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type counter struct {
+	Name    string
+	Count   int
+	Tags    []string `loop:"tags"`
+	private int
+	Skipped chan int `loop:"-"`
+}
+
+func (c *counter) Increment(by int) int {
+	c.Count += by
+	return c.Count
+}
+
+func (c counter) Describe() string {
+	return c.Name + ": " + strings.Join(c.Tags, ",")
+}
+
+func TestInterpreter_RegisterFunc(t *testing.T) {
+	interpreter := New()
+
+	interpreter.RegisterFunc("repeat", strings.Repeat)
+	interpreter.RegisterFunc("sum", func(values ...int) int {
+		total := 0
+		for _, value := range values {
+			total += value
+		}
+		return total
+	})
+	interpreter.RegisterFunc("keys", func(m map[string]int) []string {
+		keys := []string{}
+		for key := range m {
+			keys = append(keys, key)
+		}
+		return keys
+	})
+	interpreter.RegisterFunc("divide", func(a, b int) (int, error) {
+		if b == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return a / b, nil
+	})
+	interpreter.RegisterFunc("pair", func() (string, bool) { return "ok", true })
+	interpreter.RegisterFunc("describe", func(c counter) string { return c.Describe() })
+	interpreter.RegisterFunc("twice", func(fn func(int) int, value int) int { return fn(fn(value)) })
+	interpreter.RegisterFunc("join", func(sep string, values ...string) string { return strings.Join(values, sep) })
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`repeat("ab", 3)`, "ababab"},
+		{`sum()`, int64(0)},
+		{`sum(1, 2, 3)`, int64(6)},
+		{`keys({"one": 1})`, []interface{}{"one"}},
+		{`divide(10, 2)`, int64(5)},
+		{`pair()`, []interface{}{"ok", true}},
+		{`describe({"Name": "test", "tags": ["a", "b"]})`, "test: a,b"},
+		{`twice(func(x) { x * 3 }, 2)`, int64(18)},
+	}
+
+	for _, tc := range tests {
+		result, err := interpreter.Run(tc.input)
+		if err != nil {
+			t.Errorf("Run(%q) returned error: %s", tc.input, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(result, tc.expected) {
+			t.Errorf("Run(%q) returned wrong value. expected=%#v. got=%#v", tc.input, tc.expected, result)
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`divide(1, 0)`, "division by zero"},
+		{`repeat("ab")`, "WRONG NUMBER OF ARGUMENTS TO FUNCTION `repeat`. expected=2. got=1"},
+		{`repeat(1, 2)`, "ARGUMENT INVALID TYPE TO FUNCTION `repeat` (argument 0). expected=string. got=INTEGER"},
+		{`twice(func(x) { x + true }, 2)`, "TYPE-MISMATCH: INTEGER + BOOLEAN"},
+		{`twice(func(x) { "str" }, 2)`, "loop: invalid return value. expected=int. got=STRING"},
+		{`join()`, "WRONG NUMBER OF ARGUMENTS TO FUNCTION `join`. expected=at least 1. got=0"},
+	}
+
+	for _, tc := range errorTests {
+		_, err := interpreter.Run(tc.input)
+		if err == nil || err.Error() != tc.expected {
+			t.Errorf("Run(%q) returned wrong error. expected=%q. got=%v", tc.input, tc.expected, err)
+		}
+	}
+
+	if err := interpreter.RegisterFunc("invalid", 5); err == nil {
+		t.Errorf("registering a non-function should return an error")
+	}
+}
+
+func TestInterpreter_StoredCallbacks(t *testing.T) {
+	interpreter := New()
+
+	var saved func(int) int
+	var checked func(int) (int, error)
+	interpreter.RegisterFunc("on", func(cb func(int) int) { saved = cb })
+	interpreter.RegisterFunc("check", func(cb func(int) (int, error)) { checked = cb })
+
+	if _, err := interpreter.Run(`on(func(x) { x + true }); check(func(x) { x + true })`); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := saved(1); got != 0 {
+		t.Errorf("a failing callback without an error result should return zero. got=%d", got)
+	}
+
+	_, err := checked(1)
+	if runtimeErr, ok := err.(*RuntimeError); !ok || runtimeErr.Message != "TYPE-MISMATCH: INTEGER + BOOLEAN" || runtimeErr.Line != 1 {
+		t.Errorf("a failing callback should report a runtime error with its position. got=%#v", err)
+	}
+}
+
+func TestInterpreter_RegisterStruct(t *testing.T) {
+	interpreter := New()
+	c := &counter{Name: "hits", Tags: []string{"web"}}
+
+	if err := interpreter.RegisterStruct("counter", c); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`counter["Name"]`, "hits"},
+		{`counter["tags"]`, []interface{}{"web"}},
+		{`counter["private"]`, nil},
+		{`counter["Skipped"]`, nil},
+		{`counter["Increment"](2)`, int64(2)},
+		{`counter["Count"]`, int64(2)},
+		{`counter["Describe"]()`, "hits: web"},
+	}
+
+	for _, tc := range tests {
+		result, err := interpreter.Run(tc.input)
+		if err != nil {
+			t.Errorf("Run(%q) returned error: %s", tc.input, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(result, tc.expected) {
+			t.Errorf("Run(%q) returned wrong value. expected=%#v. got=%#v", tc.input, tc.expected, result)
+		}
+	}
+
+	if c.Count != 2 {
+		t.Errorf("method call did not update the Go struct. got=%d", c.Count)
+	}
+
+	if err := interpreter.RegisterStruct("invalid", 5); err == nil {
+		t.Errorf("registering a non-struct should return an error")
+	}
+}
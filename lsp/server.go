@@ -0,0 +1,467 @@
+// Package lsp implements a language server for Loop over stdio.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/kanersps/loop/analysis"
+	"github.com/kanersps/loop/ast"
+	"github.com/kanersps/loop/object/builtins"
+	"github.com/kanersps/loop/parser"
+	"github.com/kanersps/loop/parser/lexer"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+type Server struct {
+	in        *bufio.Reader
+	out       io.Writer
+	documents map[string]*document
+}
+
+// document is an open file along with the result of parsing it.
+type document struct {
+	lines   []string
+	program *ast.Program
+	info    *analysis.Info
+	errors  []parser.Error
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		documents: map[string]*document{},
+	}
+}
+
+type handler func(s *Server, params json.RawMessage) (interface{}, error)
+
+var handlers = map[string]handler{
+	"initialize":                  (*Server).initialize,
+	"shutdown":                    (*Server).shutdown,
+	"textDocument/didOpen":        (*Server).didOpen,
+	"textDocument/didChange":      (*Server).didChange,
+	"textDocument/didClose":       (*Server).didClose,
+	"textDocument/definition":     (*Server).definition,
+	"textDocument/hover":          (*Server).hover,
+	"textDocument/completion":     (*Server).completion,
+	"textDocument/documentSymbol": (*Server).documentSymbol,
+}
+
+// Run serves requests until the client sends exit or closes the input.
+func (s *Server) Run() error {
+	for {
+		msg, err := readMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(msg *request) error {
+	handle, ok := handlers[msg.Method]
+
+	// Notifications get no response, not even when they are unknown. A
+	// notification that fails is logged to the client rather than ending the
+	// session.
+	if msg.ID == nil {
+		if !ok {
+			return nil
+		}
+
+		if _, err := handle(s, msg.Params); err != nil {
+			return writeMessage(s.out, &notification{
+				JSONRPC: "2.0",
+				Method:  "window/logMessage",
+				Params:  logMessageParams{Type: messageError, Message: msg.Method + ": " + err.Error()},
+			})
+		}
+
+		return nil
+	}
+
+	if !ok {
+		return writeMessage(s.out, &errorResponse{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Error:   responseError{Code: methodNotFound, Message: "unknown method " + msg.Method},
+		})
+	}
+
+	result, err := handle(s, msg.Params)
+	if err != nil {
+		return writeMessage(s.out, &errorResponse{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Error:   responseError{Code: invalidParams, Message: err.Error()},
+		})
+	}
+
+	return writeMessage(s.out, &response{JSONRPC: "2.0", ID: msg.ID, Result: result})
+}
+
+func (s *Server) initialize(params json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync":       1, // the client sends the full text on every change
+			"definitionProvider":     true,
+			"hoverProvider":          true,
+			"completionProvider":     map[string]interface{}{},
+			"documentSymbolProvider": true,
+		},
+		"serverInfo": map[string]string{"name": "loop"},
+	}, nil
+}
+
+func (s *Server) shutdown(params json.RawMessage) (interface{}, error) {
+	return nil, nil
+}
+
+func (s *Server) didOpen(raw json.RawMessage) (interface{}, error) {
+	var params didOpenParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, err
+	}
+
+	return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+}
+
+func (s *Server) didChange(raw json.RawMessage) (interface{}, error) {
+	var params didChangeParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, err
+	}
+
+	if len(params.ContentChanges) == 0 {
+		return nil, nil
+	}
+
+	text := params.ContentChanges[len(params.ContentChanges)-1].Text
+
+	return nil, s.update(params.TextDocument.URI, text)
+}
+
+func (s *Server) didClose(raw json.RawMessage) (interface{}, error) {
+	var params didCloseParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, err
+	}
+
+	delete(s.documents, params.TextDocument.URI)
+
+	return nil, s.publishDiagnostics(params.TextDocument.URI, []diagnostic{})
+}
+
+// update parses a new version of a document and publishes its parse errors.
+func (s *Server) update(uri string, text string) error {
+	p := parser.Create(lexer.Create(text))
+	program := p.ParseProgram()
+
+	doc := &document{
+		lines:   strings.Split(text, "\n"),
+		program: program,
+		info:    analysis.Resolve(program),
+		errors:  p.Diagnostics(),
+	}
+	s.documents[uri] = doc
+
+	diagnostics := []diagnostic{}
+	for _, err := range doc.errors {
+		start := doc.toLSP(analysis.Position{Line: err.Line, Column: err.Column})
+		end := position{Line: start.Line, Character: start.Character + 1}
+
+		diagnostics = append(diagnostics, diagnostic{
+			Range:    lspRange{Start: start, End: end},
+			Severity: severityError,
+			Source:   "loop",
+			Message:  err.Message,
+		})
+	}
+
+	return s.publishDiagnostics(uri, diagnostics)
+}
+
+func (s *Server) publishDiagnostics(uri string, diagnostics []diagnostic) error {
+	return writeMessage(s.out, &notification{
+		JSONRPC: "2.0",
+		Method:  "textDocument/publishDiagnostics",
+		Params:  publishDiagnosticsParams{URI: uri, Diagnostics: diagnostics},
+	})
+}
+
+// lookup returns the document and the lexer position a request refers to.
+func (s *Server) lookup(raw json.RawMessage) (*document, string, analysis.Position, error) {
+	var params textDocumentPositionParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, "", analysis.Position{}, err
+	}
+
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, "", analysis.Position{}, fmt.Errorf("document %s is not open", params.TextDocument.URI)
+	}
+
+	return doc, params.TextDocument.URI, doc.fromLSP(params.Position), nil
+}
+
+func (s *Server) definition(raw json.RawMessage) (interface{}, error) {
+	doc, uri, pos, err := s.lookup(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	ident := doc.info.IdentifierAt(pos)
+	if ident == nil {
+		return nil, nil
+	}
+
+	binding := doc.info.Bindings[ident]
+	if binding == nil {
+		binding = doc.info.Uses[ident]
+	}
+
+	if binding == nil {
+		return nil, nil
+	}
+
+	return location{URI: uri, Range: doc.identRange(binding.Name)}, nil
+}
+
+func (s *Server) hover(raw json.RawMessage) (interface{}, error) {
+	doc, _, pos, err := s.lookup(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	ident := doc.info.IdentifierAt(pos)
+	if ident == nil {
+		return nil, nil
+	}
+
+	binding := doc.info.Bindings[ident]
+	if binding == nil {
+		binding = doc.info.Uses[ident]
+	}
+
+	var signature string
+
+	switch {
+	case binding != nil:
+		signature = describe(binding)
+	case builtins.Functions[ident.Value] != nil:
+		signature = "builtin " + ident.Value
+	default:
+		return nil, nil
+	}
+
+	identRange := doc.identRange(ident)
+
+	return hover{
+		Contents: markupContent{Kind: "markdown", Value: "```loop\n" + signature + "\n```"},
+		Range:    &identRange,
+	}, nil
+}
+
+// describe renders a binding the way it is declared, including the
+// parameters when it is bound to a function.
+func describe(binding *analysis.Binding) string {
+	if binding.Kind == analysis.Parameter {
+		return "parameter " + binding.Name.Value
+	}
+
+	if fn, ok := binding.Value.(*ast.FunctionLiteral); ok {
+		return fmt.Sprintf("func %s(%s)", binding.Name.Value, ast.ParameterList(fn.Parameters, fn.Patterns, fn.Defaults, fn.Rest))
+	}
+
+	if binding.Kind == analysis.Constant {
+		return "const " + binding.Name.Value
+	}
+
+	if binding.Kind == analysis.Type {
+		return "struct " + binding.Name.Value
+	}
+
+	return "var " + binding.Name.Value
+}
+
+func (s *Server) completion(raw json.RawMessage) (interface{}, error) {
+	doc, _, pos, err := s.lookup(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []completionItem{}
+	seen := map[string]bool{}
+
+	for scope := doc.info.ScopeAt(pos); scope != nil; scope = scope.Outer {
+		for _, binding := range scope.Bindings {
+			if seen[binding.Name.Value] {
+				continue
+			}
+			seen[binding.Name.Value] = true
+
+			kind := completionVariable
+			if _, ok := binding.Value.(*ast.FunctionLiteral); ok {
+				kind = completionFunction
+			} else if binding.Kind == analysis.Type {
+				kind = completionStruct
+			}
+
+			items = append(items, completionItem{Label: binding.Name.Value, Kind: kind, Detail: describe(binding)})
+		}
+	}
+
+	names := []string{}
+	for name := range builtins.Functions {
+		if !seen[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		items = append(items, completionItem{Label: name, Kind: completionFunction, Detail: "builtin " + name})
+	}
+
+	return items, nil
+}
+
+func (s *Server) documentSymbol(raw json.RawMessage) (interface{}, error) {
+	var params documentSymbolParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, err
+	}
+
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, fmt.Errorf("document %s is not open", params.TextDocument.URI)
+	}
+
+	return doc.symbols(doc.program.Statements), nil
+}
+
+// symbols lists the variables, functions and structs declared by
+// statements, nesting the declarations inside a function or struct under its
+// name.
+func (d *document) symbols(statements []ast.Statement) []documentSymbol {
+	symbols := []documentSymbol{}
+
+	for _, statement := range statements {
+		var name *ast.Identifier
+		var value ast.Expression
+
+		switch statement := statement.(type) {
+		case *ast.VariableStatement:
+			name, value = statement.Name, statement.Value
+		case *ast.FunctionStatement:
+			name, value = statement.Function.Name, statement.Function
+		case *ast.StructStatement:
+			symbols = append(symbols, d.structSymbol(statement))
+			continue
+		default:
+			continue
+		}
+
+		if name == nil {
+			continue
+		}
+
+		start := ast.FirstToken(statement)
+		selection := d.identRange(name)
+		symbol := documentSymbol{
+			Name:           name.Value,
+			Kind:           symbolVariable,
+			Range:          lspRange{Start: d.toLSP(analysis.Position{Line: start.Line, Column: start.Column}), End: selection.End},
+			SelectionRange: selection,
+		}
+
+		if fn, ok := value.(*ast.FunctionLiteral); ok && fn.Body != nil {
+			binding := &analysis.Binding{Name: name, Value: fn}
+			end := fn.Body.End
+
+			symbol.Kind = symbolFunction
+			symbol.Detail = describe(binding)
+			symbol.Range.End = d.toLSP(analysis.Position{Line: end.Line, Column: end.Column + 1})
+			symbol.Children = d.symbols(fn.Body.Statements)
+		}
+
+		symbols = append(symbols, symbol)
+	}
+
+	return symbols
+}
+
+func (d *document) structSymbol(statement *ast.StructStatement) documentSymbol {
+	start, end := statement.Token, statement.Body.End
+
+	return documentSymbol{
+		Name:   statement.Name.Value,
+		Detail: "struct " + statement.Name.Value,
+		Kind:   symbolStruct,
+		Range: lspRange{
+			Start: d.toLSP(analysis.Position{Line: start.Line, Column: start.Column}),
+			End:   d.toLSP(analysis.Position{Line: end.Line, Column: end.Column + 1}),
+		},
+		SelectionRange: d.identRange(statement.Name),
+		Children:       d.symbols(statement.Body.Statements),
+	}
+}
+
+func (d *document) identRange(ident *ast.Identifier) lspRange {
+	start := d.info.PositionOf(ident)
+	end := analysis.Position{Line: start.Line, Column: start.Column + len([]rune(ident.Value))}
+
+	return lspRange{Start: d.toLSP(start), End: d.toLSP(end)}
+}
+
+// toLSP converts a lexer position, counted in runes from 1, to a protocol
+// position, counted in UTF-16 code units from 0.
+func (d *document) toLSP(pos analysis.Position) position {
+	line := pos.Line - 1
+	if line < 0 {
+		return position{}
+	}
+
+	character := pos.Column - 1
+	if line < len(d.lines) {
+		runes := []rune(d.lines[line])
+		if character > len(runes) {
+			character = len(runes)
+		}
+
+		character = len(utf16.Encode(runes[:character]))
+	}
+
+	return position{Line: line, Character: character}
+}
+
+func (d *document) fromLSP(pos position) analysis.Position {
+	column := pos.Character
+
+	if pos.Line < len(d.lines) {
+		units := utf16.Encode([]rune(d.lines[pos.Line]))
+		if column > len(units) {
+			column = len(units)
+		}
+
+		column = len(utf16.Decode(units[:column]))
+	}
+
+	return analysis.Position{Line: pos.Line + 1, Column: column + 1}
+}
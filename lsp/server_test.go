@@ -0,0 +1,233 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const uri = "file:///test.loop"
+
+const source = `var add = func(a, b) {
+    var sum = a + b
+    return sum
+}
+
+add(1, 2)
+
+func double(x) {
+    return add(x, x)
+}
+`
+
+// session sends the given requests to a new server and returns every message
+// it writes back.
+func session(t *testing.T, requests ...string) []map[string]interface{} {
+	var in bytes.Buffer
+	for _, req := range requests {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(req), req)
+	}
+
+	var out bytes.Buffer
+	if err := NewServer(&in, &out).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []map[string]interface{}
+	reader := bufio.NewReader(&out)
+
+	for {
+		header, err := textproto.NewReader(reader).ReadMIMEHeader()
+		if err != nil {
+			return messages
+		}
+
+		length, _ := strconv.Atoi(header.Get("Content-Length"))
+		body := make([]byte, length)
+		io.ReadFull(reader, body)
+
+		var msg map[string]interface{}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatal(err)
+		}
+
+		messages = append(messages, msg)
+	}
+}
+
+func open(text string) string {
+	body, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "textDocument/didOpen",
+		"params": map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri, "text": text},
+		},
+	})
+
+	return string(body)
+}
+
+func call(id int, method string, line int, character int) string {
+	return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":%q,"params":{"textDocument":{"uri":%q},"position":{"line":%d,"character":%d}}}`,
+		id, method, uri, line, character)
+}
+
+func TestServer_Initialize(t *testing.T) {
+	messages := session(t,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"unknown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	)
+
+	if len(messages) != 2 {
+		t.Fatalf("wrong number of messages. got=%d", len(messages))
+	}
+
+	if !strings.Contains(fmt.Sprint(messages[0]), "definitionProvider") {
+		t.Errorf("initialize did not announce capabilities. got=%v", messages[0])
+	}
+
+	if !strings.Contains(fmt.Sprint(messages[1]), "unknown method") {
+		t.Errorf("unknown methods should return an error. got=%v", messages[1])
+	}
+}
+
+func TestServer_Diagnostics(t *testing.T) {
+	messages := session(t, open("var x = ;\nvar y = 1"))
+
+	if len(messages) != 1 || messages[0]["method"] != "textDocument/publishDiagnostics" {
+		t.Fatalf("expected diagnostics to be published. got=%v", messages)
+	}
+
+	diagnostics := messages[0]["params"].(map[string]interface{})["diagnostics"].([]interface{})
+	if len(diagnostics) == 0 {
+		t.Fatalf("expected a diagnostic")
+	}
+
+	start := diagnostics[0].(map[string]interface{})["range"].(map[string]interface{})["start"]
+	if fmt.Sprint(start) != "map[character:8 line:0]" {
+		t.Errorf("wrong diagnostic position. got=%v", start)
+	}
+}
+
+func TestServer_MalformedNotification(t *testing.T) {
+	messages := session(t,
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":5}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didChange","params":5}`,
+		`{"jsonrpc":"2.0","id":1,"method":"shutdown"}`,
+	)
+
+	if len(messages) != 3 {
+		t.Fatalf("expected the server to keep running. got=%v", messages)
+	}
+
+	for _, msg := range messages[:2] {
+		if msg["method"] != "window/logMessage" {
+			t.Errorf("expected the failure to be logged. got=%v", msg)
+		}
+	}
+
+	if fmt.Sprint(messages[2]["id"]) != "1" {
+		t.Errorf("expected a response to shutdown. got=%v", messages[2])
+	}
+}
+
+// The results below are requests sent after opening source, one per test.
+func result(t *testing.T, request string) interface{} {
+	messages := session(t, open(source), request)
+
+	if len(messages) != 2 {
+		t.Fatalf("wrong number of messages. got=%d", len(messages))
+	}
+
+	return messages[1]["result"]
+}
+
+func TestServer_Definition(t *testing.T) {
+	tests := []struct {
+		line      int
+		character int
+		expected  string // the start of the definition, empty for none
+	}{
+		{5, 1, "map[character:4 line:0]"},   // add
+		{2, 12, "map[character:8 line:1]"},  // sum
+		{1, 14, "map[character:15 line:0]"}, // a
+		{5, 4, ""},                          // the number 1
+		{8, 12, "map[character:4 line:0]"},  // add inside double
+	}
+
+	for _, tc := range tests {
+		location := result(t, call(1, "textDocument/definition", tc.line, tc.character))
+
+		if tc.expected == "" {
+			if location != nil {
+				t.Errorf("expected no definition at %d:%d. got=%v", tc.line, tc.character, location)
+			}
+			continue
+		}
+
+		if location == nil {
+			t.Errorf("no definition at %d:%d", tc.line, tc.character)
+			continue
+		}
+
+		start := location.(map[string]interface{})["range"].(map[string]interface{})["start"]
+		if fmt.Sprint(start) != tc.expected {
+			t.Errorf("wrong definition at %d:%d. expected=%s. got=%v", tc.line, tc.character, tc.expected, start)
+		}
+	}
+}
+
+func TestServer_Hover(t *testing.T) {
+	hover := result(t, call(1, "textDocument/hover", 5, 0))
+
+	value := hover.(map[string]interface{})["contents"].(map[string]interface{})["value"]
+	if !strings.Contains(value.(string), "func add(a, b)") {
+		t.Errorf("hover should show the parameters. got=%q", value)
+	}
+}
+
+func TestServer_Completion(t *testing.T) {
+	items := result(t, call(1, "textDocument/completion", 2, 4)).([]interface{})
+
+	labels := map[string]bool{}
+	for _, item := range items {
+		labels[item.(map[string]interface{})["label"].(string)] = true
+	}
+
+	for _, expected := range []string{"add", "sum", "a", "b", "len", "println"} {
+		if !labels[expected] {
+			t.Errorf("completion is missing %q", expected)
+		}
+	}
+}
+
+func TestServer_DocumentSymbol(t *testing.T) {
+	request := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"textDocument/documentSymbol","params":{"textDocument":{"uri":%q}}}`, uri)
+	symbols := result(t, request).([]interface{})
+
+	if len(symbols) != 2 {
+		t.Fatalf("wrong number of symbols. got=%d", len(symbols))
+	}
+
+	add := symbols[0].(map[string]interface{})
+	if add["name"] != "add" || add["detail"] != "func add(a, b)" {
+		t.Errorf("wrong symbol. got=%v", add)
+	}
+
+	double := symbols[1].(map[string]interface{})
+	if double["name"] != "double" || double["detail"] != "func double(x)" {
+		t.Errorf("wrong symbol. got=%v", double)
+	}
+
+	children := add["children"].([]interface{})
+	if len(children) != 1 || children[0].(map[string]interface{})["name"] != "sum" {
+		t.Errorf("wrong children. got=%v", children)
+	}
+}
@@ -1,14 +1,55 @@
 package models
 
+import (
+	"bufio"
+	"github.com/kanersps/loop/ast"
+	"io"
+	"os"
+)
+
+// Streams are the input and output used by builtins such as print and input.
+type Streams struct {
+	In  *bufio.Reader
+	Out io.Writer
+	Err io.Writer
+}
+
+var defaultStreams = NewStreams(os.Stdin, os.Stdout, os.Stderr)
+
+func NewStreams(in io.Reader, out io.Writer, err io.Writer) *Streams {
+	reader, ok := in.(*bufio.Reader)
+	if !ok {
+		reader = bufio.NewReader(in)
+	}
+
+	return &Streams{In: reader, Out: out, Err: err}
+}
+
 type Environment struct {
-	Store map[string]Object
-	Outer *Environment
+	Store   map[string]Object
+	Outer   *Environment
+	Streams *Streams
+
+	// Strict makes conditions that are not booleans an error instead of
+	// applying IsTruthy. It applies to every environment enclosed by this one.
+	Strict bool
+
+	// AllowShadowing lets declarations reuse the names of builtin functions.
+	// It applies to every environment enclosed by this one.
+	AllowShadowing bool
+
+	// Yield hands a value to the consumer of the generator running in this
+	// environment and waits until the next value is asked for. It returns
+	// false when the consumer stopped instead.
+	Yield func(Object) bool
+
+	constants map[string]ast.Node // the declaration of each constant
 }
 
 func (e *Environment) Set(name string, value Object) Object {
 	// TODO: recursive search
 	if e.Outer != nil {
-		if _, exists := e.Outer.Store[name]; exists {
+		if _, exists := e.Outer.Store[name]; exists && e.Outer.constants[name] == nil {
 			e.Outer.Store[name] = value
 		}
 	}
@@ -17,6 +58,25 @@ func (e *Environment) Set(name string, value Object) Object {
 	return e.Store[name]
 }
 
+// SetConstant binds name to value in this environment and marks it as a
+// constant declared by declaration.
+func (e *Environment) SetConstant(name string, value Object, declaration ast.Node) {
+	if e.constants == nil {
+		e.constants = map[string]ast.Node{}
+	}
+
+	e.constants[name] = declaration
+	e.Store[name] = value
+}
+
+// Constant returns the declaration of name when it is a constant of this
+// environment. Set leaves constants of the outer environment alone, so an
+// enclosed environment may declare the name again.
+func (e *Environment) Constant(name string) (ast.Node, bool) {
+	declaration := e.constants[name]
+	return declaration, declaration != nil
+}
+
 func (e *Environment) Get(name string) (Object, bool) {
 	obj, ok := e.Store[name]
 
@@ -26,3 +86,51 @@ func (e *Environment) Get(name string) (Object, bool) {
 
 	return obj, ok
 }
+
+// IO returns the streams of the nearest environment that has them, falling
+// back to the process' standard streams.
+func (e *Environment) IO() *Streams {
+	for env := e; env != nil; env = env.Outer {
+		if env.Streams != nil {
+			return env.Streams
+		}
+	}
+
+	return defaultStreams
+}
+
+// Yielder returns the Yield of the nearest environment that has one, or nil
+// outside of a generator.
+func (e *Environment) Yielder() func(Object) bool {
+	for env := e; env != nil; env = env.Outer {
+		if env.Yield != nil {
+			return env.Yield
+		}
+	}
+
+	return nil
+}
+
+// IsShadowingAllowed reports whether this environment or one it is enclosed
+// by allows declarations to shadow builtins.
+func (e *Environment) IsShadowingAllowed() bool {
+	for env := e; env != nil; env = env.Outer {
+		if env.AllowShadowing {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsStrict reports whether this environment or one it is enclosed by is in
+// strict mode.
+func (e *Environment) IsStrict() bool {
+	for env := e; env != nil; env = env.Outer {
+		if env.Strict {
+			return true
+		}
+	}
+
+	return false
+}
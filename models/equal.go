@@ -0,0 +1,126 @@
+package models
+
+import "strings"
+
+// Equal reports whether two objects hold the same value. Arrays and hashes
+// are compared element by element, sets by their members regardless of
+// order and instances of the same struct field by field; functions only
+// equal themselves.
+func Equal(a, b Object) bool {
+	if a == b {
+		return true
+	}
+
+	if a == nil || b == nil || a.Type() != b.Type() {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		return a.Value == b.(*Integer).Value
+	case *Boolean:
+		return a.Value == b.(*Boolean).Value
+	case *String:
+		return a.Value == b.(*String).Value
+	case *Null:
+		return true
+	case *Array:
+		other := b.(*Array)
+		if len(a.Elements) != len(other.Elements) {
+			return false
+		}
+
+		for i, element := range a.Elements {
+			if !Equal(element, other.Elements[i]) {
+				return false
+			}
+		}
+
+		return true
+	case *Hash:
+		other := b.(*Hash)
+		if len(a.Pairs) != len(other.Pairs) {
+			return false
+		}
+
+		for _, pair := range a.Pairs {
+			value, ok := other.Get(pair.Key)
+			if !ok || !Equal(pair.Value, value) {
+				return false
+			}
+		}
+
+		return true
+	case *Set:
+		other := b.(*Set)
+		if len(a.Elements) != len(other.Elements) {
+			return false
+		}
+
+		for _, element := range a.Elements {
+			if !other.Has(element) {
+				return false
+			}
+		}
+
+		return true
+	case *Instance:
+		other := b.(*Instance)
+		if a.Struct != other.Struct {
+			return false
+		}
+
+		for name, value := range a.Fields {
+			if !Equal(value, other.Fields[name]) {
+				return false
+			}
+		}
+
+		return true
+	case *Error:
+		return a.Message == b.(*Error).Message
+	}
+
+	return false
+}
+
+// Compare orders two values, returning -1, 0 or 1. Integers compare by
+// value, strings lexicographically and arrays element by element, with a
+// prefix ordered before the longer array. ok is false when the values have
+// no order, such as hashes or values of different types.
+func Compare(a, b Object) (result int, ok bool) {
+	if a == nil || b == nil || a.Type() != b.Type() {
+		return 0, false
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		return compareOrdered(a.Value < b.(*Integer).Value, a.Value > b.(*Integer).Value), true
+	case *String:
+		return strings.Compare(a.Value, b.(*String).Value), true
+	case *Array:
+		other := b.(*Array)
+
+		for i := 0; i < len(a.Elements) && i < len(other.Elements); i++ {
+			result, ok := Compare(a.Elements[i], other.Elements[i])
+			if !ok || result != 0 {
+				return result, ok
+			}
+		}
+
+		return compareOrdered(len(a.Elements) < len(other.Elements), len(a.Elements) > len(other.Elements)), true
+	}
+
+	return 0, false
+}
+
+func compareOrdered(less bool, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+
+	return 0
+}
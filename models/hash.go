@@ -0,0 +1,113 @@
+package models
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+)
+
+// HashKeyOf returns the key obj is stored under in a hash. ok is false for
+// values that cannot be hash keys.
+func HashKeyOf(obj Object) (key HashKey, ok bool) {
+	if array, isArray := obj.(*Array); isArray && !array.hashable() {
+		return HashKey{}, false
+	}
+
+	hashable, ok := obj.(Hashable)
+	if !ok {
+		return HashKey{}, false
+	}
+
+	return hashable.HashKey(), true
+}
+
+func (array *Array) hashable() bool {
+	if !array.Frozen {
+		return false
+	}
+
+	for _, element := range array.Elements {
+		if _, ok := HashKeyOf(element); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// HashKey combines the keys of the elements. It is only meaningful for
+// frozen arrays, see HashKeyOf.
+func (array *Array) HashKey() HashKey {
+	if array.hashKey != nil {
+		return *array.hashKey
+	}
+
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+
+	for _, element := range array.Elements {
+		key, _ := HashKeyOf(element)
+
+		h.Write([]byte(key.Type))
+		binary.LittleEndian.PutUint64(buf, key.Value)
+		h.Write(buf)
+	}
+
+	key := HashKey{Type: ARRAY, Value: h.Sum64()}
+	if array.Frozen {
+		array.hashKey = &key
+	}
+
+	return key
+}
+
+// slot finds where key is stored, or where it would be stored. Starting at
+// the key's own HashKey, it steps past pairs holding a different key whose
+// HashKey collided.
+func (h *Hash) slot(key Object, hashKey HashKey) HashKey {
+	for {
+		pair, ok := h.Pairs[hashKey]
+		if !ok || Equal(pair.Key, key) {
+			return hashKey
+		}
+
+		hashKey = probe(hashKey)
+	}
+}
+
+func probe(key HashKey) HashKey {
+	// A multiplicative step visits a different sequence of slots for each
+	// starting key.
+	key.Value = key.Value*6364136223846793005 + 1442695040888963407
+	return key
+}
+
+// Get returns the value stored under key.
+func (h *Hash) Get(key Object) (Object, bool) {
+	hashKey, ok := HashKeyOf(key)
+	if !ok {
+		return nil, false
+	}
+
+	pair, ok := h.Pairs[h.slot(key, hashKey)]
+	if !ok {
+		return nil, false
+	}
+
+	return pair.Value, true
+}
+
+// Set stores value under key, replacing the value of an equal key. It
+// reports false when key cannot be used as a hash key.
+func (h *Hash) Set(key Object, value Object) bool {
+	hashKey, ok := HashKeyOf(key)
+	if !ok {
+		return false
+	}
+
+	if h.Pairs == nil {
+		h.Pairs = map[HashKey]HashPair{}
+	}
+
+	h.Pairs[h.slot(key, hashKey)] = HashPair{Key: key, Value: value}
+	return true
+}
@@ -0,0 +1,60 @@
+package models
+
+// Iterator produces values one at a time, for a for loop or a builtin such
+// as map. Once it is exhausted it keeps returning nil.
+type Iterator struct {
+	// Next returns the next value, nil when there are no more, or an error
+	// that ends the iteration.
+	Next func() Object
+	// Stop releases an iterator that will not be exhausted, such as a
+	// generator that is left suspended. It may be nil.
+	Stop func()
+}
+
+func (it *Iterator) Type() ObjectType { return ITERATOR }
+func (it *Iterator) Inspect() string  { return "iterator" }
+
+// Close stops the iterator when it needs to be stopped.
+func (it *Iterator) Close() {
+	if it.Stop != nil {
+		it.Stop()
+	}
+}
+
+// Iterate returns an iterator over the values of obj: the elements of an
+// array, the members of a set, the characters of a string and the [key,
+// value] pairs of a hash, in no particular order. An iterator iterates over
+// itself. ok is false for anything else.
+func Iterate(obj Object) (iterator *Iterator, ok bool) {
+	var values []Object
+
+	switch obj := obj.(type) {
+	case *Iterator:
+		return obj, true
+	case *Array:
+		values = obj.Elements
+	case *Set:
+		values = obj.Elements
+	case *String:
+		for _, r := range obj.Value {
+			values = append(values, &String{Value: string(r)})
+		}
+	case *Hash:
+		for _, pair := range obj.Pairs {
+			values = append(values, &Array{Elements: []Object{pair.Key, pair.Value}})
+		}
+	default:
+		return nil, false
+	}
+
+	index := 0
+
+	return &Iterator{Next: func() Object {
+		if index >= len(values) {
+			return nil
+		}
+
+		index++
+		return values[index-1]
+	}}, true
+}
@@ -19,6 +19,10 @@ const (
 	BUILTIN   = "BUILTIN"
 	ARRAY     = "ARRAY"
 	HASH      = "HASH"
+	SET       = "SET"
+	STRUCT    = "STRUCT"
+	INSTANCE  = "INSTANCE"
+	ITERATOR  = "ITERATOR"
 )
 
 type ObjectType string
@@ -33,12 +37,18 @@ type HashPair struct {
 	Value Object
 }
 
+// Hash maps keys to values. Pairs is indexed by HashKey; a key whose
+// HashKey collides with a different key is stored under a probed HashKey, so
+// look keys up with Get and store them with Set. A frozen hash cannot be
+// changed.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	Pairs  map[HashKey]HashPair
+	Frozen bool
 }
 
+// Hashable values can be used as hash keys. Use HashKeyOf rather than
+// asserting this interface, since arrays are only hashable once frozen.
 type Hashable interface {
-	// TODO: Cache return values of the HashKey method to improve performance
 	HashKey() HashKey
 }
 
@@ -100,30 +110,45 @@ type Return struct {
 func (r *Return) Type() ObjectType { return RETURN }
 func (r *Return) Inspect() string  { return r.Inspect() }
 
-// TODO: Implement line number & column number to more easily debug issues
+// Error is a runtime error. Line and Column are zero until the evaluator
+// records where the error was raised.
 type Error struct {
 	Message string
+	Line    int
+	Column  int
 }
 
 func (e *Error) Type() ObjectType { return ERROR }
-func (e *Error) Inspect() string  { return "Exception: " + e.Message }
+func (e *Error) Inspect() string {
+	if e.Line == 0 {
+		return "Exception: " + e.Message
+	}
+
+	return fmt.Sprintf("Exception: %s (at %d:%d)", e.Message, e.Line, e.Column)
+}
 
 type Function struct {
+	Name       string // empty for anonymous functions
 	Parameters []*ast.Identifier
+	Patterns   []ast.Expression
+	Defaults   []ast.Expression
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Generator  bool
+	Expression bool   // a function expression, which binds its own name in each call
+	Self       Object // the instance a method is bound to, bound as self in each call
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION }
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
 	out.WriteString("func")
+	if f.Name != "" {
+		out.WriteString(" " + f.Name)
+	}
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(ast.ParameterList(f.Parameters, f.Patterns, f.Defaults, f.Rest))
 	out.WriteString(") {\n")
 	out.WriteString(f.Body.String())
 	out.WriteString("\n}")
@@ -132,6 +157,8 @@ func (f *Function) Inspect() string {
 
 type String struct {
 	Value string
+
+	hashKey *HashKey // cached by HashKey; strings are never modified
 }
 
 func (s *String) Type() ObjectType { return STRING }
@@ -139,10 +166,14 @@ func (s *String) Inspect() string {
 	return s.Value
 }
 func (s *String) HashKey() HashKey {
-	h := fnv.New64a()
-	h.Write([]byte(s.Value))
+	if s.hashKey == nil {
+		h := fnv.New64a()
+		h.Write([]byte(s.Value))
+
+		s.hashKey = &HashKey{Type: s.Type(), Value: h.Sum64()}
+	}
 
-	return HashKey{Type: s.Type(), Value: h.Sum64()}
+	return *s.hashKey
 }
 
 type BuiltinFunction func(env *Environment, args ...Object) Object
@@ -155,8 +186,13 @@ type Builtin struct {
 func (b *Builtin) Type() ObjectType { return BUILTIN }
 func (b *Builtin) Inspect() string  { return "builtin function" }
 
+// Array is a list of values. A frozen array cannot be changed and, when
+// all of its elements are hashable, can be used as a hash key.
 type Array struct {
 	Elements []Object
+	Frozen   bool
+
+	hashKey *HashKey // cached by HashKey once frozen
 }
 
 func (array *Array) Type() ObjectType { return ARRAY }
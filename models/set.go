@@ -0,0 +1,88 @@
+package models
+
+import "strings"
+
+// Set is a collection of distinct hashable values. Members are kept in the
+// order they were first added, which is the order they are iterated and
+// printed in.
+type Set struct {
+	Elements []Object
+
+	index Hash // maps every member to itself
+}
+
+// NewSet builds a set from elements, dropping duplicates. It returns the
+// first element that cannot be a member when there is one.
+func NewSet(elements ...Object) (*Set, Object) {
+	set := &Set{}
+
+	for _, element := range elements {
+		if !set.Add(element) {
+			return nil, element
+		}
+	}
+
+	return set, nil
+}
+
+func (s *Set) Type() ObjectType { return SET }
+func (s *Set) Inspect() string {
+	members := make([]string, len(s.Elements))
+	for i, element := range s.Elements {
+		members[i] = element.Inspect()
+	}
+
+	return "#{" + strings.Join(members, ", ") + "}"
+}
+
+// Add inserts obj unless an equal member is present. It reports false when
+// obj is not hashable.
+func (s *Set) Add(obj Object) bool {
+	if _, ok := HashKeyOf(obj); !ok {
+		return false
+	}
+
+	if !s.Has(obj) {
+		s.index.Set(obj, obj)
+		s.Elements = append(s.Elements, obj)
+	}
+
+	return true
+}
+
+// Has reports whether an equal value is a member.
+func (s *Set) Has(obj Object) bool {
+	_, ok := s.index.Get(obj)
+	return ok
+}
+
+// Union returns the members of either set.
+func (s *Set) Union(other *Set) *Set {
+	union, _ := NewSet(s.Elements...)
+	for _, element := range other.Elements {
+		union.Add(element)
+	}
+
+	return union
+}
+
+// Intersection returns the members of s that are also in other.
+func (s *Set) Intersection(other *Set) *Set {
+	return s.filter(func(obj Object) bool { return other.Has(obj) })
+}
+
+// Difference returns the members of s that are not in other.
+func (s *Set) Difference(other *Set) *Set {
+	return s.filter(func(obj Object) bool { return !other.Has(obj) })
+}
+
+func (s *Set) filter(keep func(Object) bool) *Set {
+	result := &Set{}
+	for _, element := range s.Elements {
+		if keep(element) {
+			result.Add(element)
+		}
+	}
+
+	return result
+}
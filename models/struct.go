@@ -0,0 +1,50 @@
+package models
+
+import (
+	"github.com/kanersps/loop/ast"
+	"strings"
+)
+
+// Struct is a user type declared with `struct`. Calling it creates an
+// instance.
+type Struct struct {
+	Name    string
+	Fields  []*ast.VariableStatement // in declaration order
+	Methods map[string]*Function
+	Env     *Environment // the field defaults are evaluated here
+}
+
+func (s *Struct) Type() ObjectType { return STRUCT }
+func (s *Struct) Inspect() string  { return "struct " + s.Name }
+
+// Instance is a value of a user type. Its fields can be assigned to unless
+// it is frozen.
+type Instance struct {
+	Struct *Struct
+	Fields map[string]Object
+	Frozen bool
+}
+
+func (i *Instance) Type() ObjectType { return INSTANCE }
+func (i *Instance) Inspect() string {
+	fields := make([]string, len(i.Struct.Fields))
+	for n, field := range i.Struct.Fields {
+		fields[n] = field.Name.Value + ": " + i.Fields[field.Name.Value].Inspect()
+	}
+
+	return i.Struct.Name + "{" + strings.Join(fields, ", ") + "}"
+}
+
+// Method returns the method name of the instance's struct with self bound to
+// the instance.
+func (i *Instance) Method(name string) (*Function, bool) {
+	method, ok := i.Struct.Methods[name]
+	if !ok {
+		return nil, false
+	}
+
+	bound := *method
+	bound.Self = i
+
+	return &bound, true
+}
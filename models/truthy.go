@@ -0,0 +1,25 @@
+package models
+
+// IsTruthy is the truthiness rule used by if, while, !, && and ||. false,
+// null, 0, the empty string and empty arrays, hashes and sets are false;
+// every other value is true.
+func IsTruthy(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Boolean:
+		return obj.Value
+	case *Null:
+		return false
+	case *Integer:
+		return obj.Value != 0
+	case *String:
+		return obj.Value != ""
+	case *Array:
+		return len(obj.Elements) != 0
+	case *Hash:
+		return len(obj.Pairs) != 0
+	case *Set:
+		return len(obj.Elements) != 0
+	}
+
+	return obj != nil
+}
@@ -3,8 +3,11 @@ package builtins
 import (
 	"fmt"
 	"github.com/kanersps/loop/models"
+	"io"
 	"log"
 	"net/http"
+	"strings"
+	"unicode/utf8"
 )
 
 type HttpEndpoint struct {
@@ -42,6 +45,145 @@ func SetApplyFunction(a applyFunction) {
 	ApplyFunction = a
 }
 
+// readLine returns the next line from the environment's input without its
+// line ending, or null once the input is exhausted.
+func readLine(env *models.Environment) models.Object {
+	line, err := env.IO().In.ReadString('\n')
+
+	if err != nil && line == "" {
+		if err == io.EOF {
+			return models.NULL
+		}
+
+		return &models.Error{Message: fmt.Sprintf("FAILED TO READ INPUT: %s", err)}
+	}
+
+	return &models.String{Value: strings.TrimRight(line, "\r\n")}
+}
+
+// assertionFailed builds the error raised by the assert builtins. A message
+// passed as the optional last argument replaces the generated description.
+func assertionFailed(message []models.Object, format string, a ...interface{}) *models.Error {
+	if len(message) == 1 {
+		return &models.Error{Message: "ASSERTION FAILED: " + message[0].Inspect()}
+	}
+
+	return &models.Error{Message: "ASSERTION FAILED: " + fmt.Sprintf(format, a...)}
+}
+
+// freeze returns a frozen copy of an array or hash, freezing the arrays and
+// hashes inside it as well. Other values are returned as they are.
+func freeze(obj models.Object) models.Object {
+	switch obj := obj.(type) {
+	case *models.Array:
+		if obj.Frozen {
+			return obj
+		}
+
+		elements := make([]models.Object, len(obj.Elements))
+		for i, element := range obj.Elements {
+			elements[i] = freeze(element)
+		}
+
+		return &models.Array{Elements: elements, Frozen: true}
+	case *models.Hash:
+		if obj.Frozen {
+			return obj
+		}
+
+		hash := &models.Hash{Pairs: make(map[models.HashKey]models.HashPair, len(obj.Pairs)), Frozen: true}
+		for hashKey, pair := range obj.Pairs {
+			hash.Pairs[hashKey] = models.HashPair{Key: pair.Key, Value: freeze(pair.Value)}
+		}
+
+		return hash
+	case *models.Instance:
+		if obj.Frozen {
+			return obj
+		}
+
+		fields := make(map[string]models.Object, len(obj.Fields))
+		for name, value := range obj.Fields {
+			fields[name] = freeze(value)
+		}
+
+		return &models.Instance{Struct: obj.Struct, Fields: fields, Frozen: true}
+	}
+
+	return obj
+}
+
+// Iterate returns an iterator over obj. Instances are iterated by calling
+// their __iter__ method and iterating over what it returns instead.
+func Iterate(obj models.Object) (*models.Iterator, *models.Error) {
+	if instance, ok := obj.(*models.Instance); ok {
+		if method, ok := instance.Method("__iter__"); ok {
+			obj = ApplyFunction(method, []models.Object{}, instance.Struct.Env)
+
+			if err, ok := obj.(*models.Error); ok {
+				return nil, err
+			}
+		}
+	}
+
+	iterator, ok := models.Iterate(obj)
+	if !ok {
+		return nil, &models.Error{Message: fmt.Sprintf("CANNOT ITERATE OVER %s", obj.Type())}
+	}
+
+	return iterator, nil
+}
+
+// Equal reports whether a == b. An instance on the left that defines __eq__
+// is compared by calling it, which has to return a boolean, and anything else
+// with models.Equal.
+func Equal(a, b models.Object) (bool, *models.Error) {
+	instance, ok := a.(*models.Instance)
+	if !ok {
+		return models.Equal(a, b), nil
+	}
+
+	method, ok := instance.Method("__eq__")
+	if !ok {
+		return models.Equal(a, b), nil
+	}
+
+	result := ApplyFunction(method, []models.Object{b}, instance.Struct.Env)
+	if err, ok := result.(*models.Error); ok {
+		return false, err
+	}
+
+	equal, ok := result.(*models.Boolean)
+	if !ok {
+		if result == nil {
+			result = models.NULL
+		}
+
+		return false, &models.Error{Message: fmt.Sprintf("__eq__ OF %s RETURNED %s. expected=BOOLEAN", instance.Struct.Name, result.Type())}
+	}
+
+	return equal.Value, nil
+}
+
+// setOperation wraps a method combining two sets as a builtin.
+func setOperation(name string, operation func(a, b *models.Set) *models.Set) *models.Builtin {
+	return &models.Builtin{
+		Func: func(env *models.Environment, args ...models.Object) models.Object {
+			if len(args) != 2 {
+				return &models.Error{Message: fmt.Sprintf("WRONG NUMBER OF ARGUMENTS TO BUILT-IN FUNCTION `%s`. expected=2. got=%d", name, len(args))}
+			}
+
+			for idx, arg := range args {
+				if _, ok := arg.(*models.Set); !ok {
+					return &models.Error{Message: fmt.Sprintf("ARGUMENT INVALID TYPE TO BUILT-IN FUNCTION `%s` (argument %d). expected=SET. got=%v", name, idx, arg.Type())}
+				}
+			}
+
+			return operation(args[0].(*models.Set), args[1].(*models.Set))
+		},
+	}
+}
+
 var Functions = map[string]*models.Builtin{
 	"len": {
 		Func: func(env *models.Environment, args ...models.Object) models.Object {
@@ -52,7 +194,7 @@ var Functions = map[string]*models.Builtin{
 			stringArg, ok := args[0].(*models.String)
 
 			if ok {
-				return &models.Integer{Value: int64(len(stringArg.Value))}
+				return &models.Integer{Value: int64(utf8.RuneCountInString(stringArg.Value))}
 			}
 
 			arrayArg, ok := args[0].(*models.Array)
@@ -61,6 +203,18 @@ var Functions = map[string]*models.Builtin{
 				return &models.Integer{Value: int64(len(arrayArg.Elements))}
 			}
 
+			setArg, ok := args[0].(*models.Set)
+
+			if ok {
+				return &models.Integer{Value: int64(len(setArg.Elements))}
+			}
+
+			if instance, ok := args[0].(*models.Instance); ok {
+				if method, ok := instance.Method("__len__"); ok {
+					return ApplyFunction(method, []models.Object{}, env)
+				}
+			}
+
 			return &models.Error{Message: fmt.Sprintf("ARGUMENT INVALID TYPE TO BUILT-IN FUNCTION `len`. got=%v. expected=STRING", args[0].Type())}
 		},
 	},
@@ -76,13 +230,184 @@ var Functions = map[string]*models.Builtin{
 				return &models.Error{Message: fmt.Sprintf("ARGUMENT INVALID TYPE TO BUILT-IN FUNCTION `append` (argument 0). expected=ARRAY. got=%v", args[0])}
 			}
 
-			return &models.Array{Elements: append(array.Elements, args[1:]...)}
+			elements := make([]models.Object, 0, len(array.Elements)+len(args)-1)
+			elements = append(elements, array.Elements...)
+
+			return &models.Array{Elements: append(elements, args[1:]...)}
+		},
+	},
+	"freeze": {
+		Func: func(env *models.Environment, args ...models.Object) models.Object {
+			if len(args) != 1 {
+				return &models.Error{Message: fmt.Sprintf("WRONG NUMBER OF ARGUMENTS TO BUILT-IN FUNCTION `freeze`. expected=1. got=%d", len(args))}
+			}
+
+			return freeze(args[0])
+		},
+	},
+	"type_of": {
+		Func: func(env *models.Environment, args ...models.Object) models.Object {
+			if len(args) != 1 {
+				return &models.Error{Message: fmt.Sprintf("WRONG NUMBER OF ARGUMENTS TO BUILT-IN FUNCTION `type_of`. expected=1. got=%d", len(args))}
+			}
+
+			if instance, ok := args[0].(*models.Instance); ok {
+				return &models.String{Value: instance.Struct.Name}
+			}
+
+			return &models.String{Value: string(args[0].Type())}
 		},
 	},
+	"map": {
+		Func: func(env *models.Environment, args ...models.Object) models.Object {
+			if len(args) != 2 {
+				return &models.Error{Message: fmt.Sprintf("WRONG NUMBER OF ARGUMENTS TO BUILT-IN FUNCTION `map`. expected=2. got=%d", len(args))}
+			}
+
+			source, err := Iterate(args[0])
+			if err != nil {
+				return err
+			}
+
+			return &models.Iterator{
+				Next: func() models.Object {
+					value := source.Next()
+					if value == nil || value.Type() == models.ERROR {
+						return value
+					}
+
+					return ApplyFunction(args[1], []models.Object{value}, env)
+				},
+				Stop: source.Close,
+			}
+		},
+	},
+	"take": {
+		Func: func(env *models.Environment, args ...models.Object) models.Object {
+			if len(args) != 2 {
+				return &models.Error{Message: fmt.Sprintf("WRONG NUMBER OF ARGUMENTS TO BUILT-IN FUNCTION `take`. expected=2. got=%d", len(args))}
+			}
+
+			count, ok := args[1].(*models.Integer)
+			if !ok {
+				return &models.Error{Message: fmt.Sprintf("ARGUMENT INVALID TYPE TO BUILT-IN FUNCTION `take` (argument 1). expected=INTEGER. got=%v", args[1].Type())}
+			}
+
+			source, err := Iterate(args[0])
+			if err != nil {
+				return err
+			}
+
+			taken := int64(0)
+
+			return &models.Iterator{
+				Next: func() models.Object {
+					if taken >= count.Value {
+						source.Close()
+						return nil
+					}
+
+					taken++
+					return source.Next()
+				},
+				Stop: source.Close,
+			}
+		},
+	},
+	"collect": {
+		Func: func(env *models.Environment, args ...models.Object) models.Object {
+			if len(args) != 1 {
+				return &models.Error{Message: fmt.Sprintf("WRONG NUMBER OF ARGUMENTS TO BUILT-IN FUNCTION `collect`. expected=1. got=%d", len(args))}
+			}
+
+			source, err := Iterate(args[0])
+			if err != nil {
+				return err
+			}
+			defer source.Close()
+
+			elements := []models.Object{}
+			for value := source.Next(); value != nil; value = source.Next() {
+				if value.Type() == models.ERROR {
+					return value
+				}
+
+				elements = append(elements, value)
+			}
+
+			return &models.Array{Elements: elements}
+		},
+	},
+	"range": {
+		Func: func(env *models.Environment, args ...models.Object) models.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return &models.Error{Message: fmt.Sprintf("WRONG NUMBER OF ARGUMENTS TO BUILT-IN FUNCTION `range`. expected=1 to 2. got=%d", len(args))}
+			}
+
+			bounds := make([]int64, len(args))
+			for idx, arg := range args {
+				integer, ok := arg.(*models.Integer)
+				if !ok {
+					return &models.Error{Message: fmt.Sprintf("ARGUMENT INVALID TYPE TO BUILT-IN FUNCTION `range` (argument %d). expected=INTEGER. got=%v", idx, arg.Type())}
+				}
+
+				bounds[idx] = integer.Value
+			}
+
+			// range(end) counts from 0, range(start, end) from start.
+			next, end := int64(0), bounds[0]
+			if len(bounds) == 2 {
+				next, end = bounds[0], bounds[1]
+			}
+
+			return &models.Iterator{
+				Next: func() models.Object {
+					if next >= end {
+						return nil
+					}
+
+					next++
+					return &models.Integer{Value: next - 1}
+				},
+			}
+		},
+	},
+	"set": {
+		Func: func(env *models.Environment, args ...models.Object) models.Object {
+			if len(args) >= 2 {
+				return &models.Error{Message: fmt.Sprintf("WRONG NUMBER OF ARGUMENTS TO BUILT-IN FUNCTION `set`. expected=1. got=%d", len(args))}
+			}
+
+			if len(args) == 0 {
+				return &models.Set{}
+			}
+
+			var elements []models.Object
+
+			switch arg := args[0].(type) {
+			case *models.Array:
+				elements = arg.Elements
+			case *models.Set:
+				elements = arg.Elements
+			default:
+				return &models.Error{Message: fmt.Sprintf("ARGUMENT INVALID TYPE TO BUILT-IN FUNCTION `set` (argument 0). expected=ARRAY. got=%v", args[0].Type())}
+			}
+
+			set, invalid := models.NewSet(elements...)
+			if invalid != nil {
+				return &models.Error{Message: fmt.Sprintf("SET MEMBER IS INCORRECT TYPE. got=%s", invalid.Type())}
+			}
+
+			return set
+		},
+	},
+	"union":        setOperation("union", (*models.Set).Union),
+	"intersection": setOperation("intersection", (*models.Set).Intersection),
+	"difference":   setOperation("difference", (*models.Set).Difference),
 	"print": {
 		Func: func(env *models.Environment, args ...models.Object) models.Object {
 			for _, arg := range args {
-				fmt.Print(arg.Inspect())
+				fmt.Fprint(env.IO().Out, arg.Inspect())
 			}
 
 			return models.NULL
@@ -91,7 +416,111 @@ var Functions = map[string]*models.Builtin{
 	"println": {
 		Func: func(env *models.Environment, args ...models.Object) models.Object {
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				fmt.Fprintln(env.IO().Out, arg.Inspect())
+			}
+
+			return models.NULL
+		},
+	},
+	"eprint": {
+		Func: func(env *models.Environment, args ...models.Object) models.Object {
+			for _, arg := range args {
+				fmt.Fprint(env.IO().Err, arg.Inspect())
+			}
+
+			return models.NULL
+		},
+	},
+	"eprintln": {
+		Func: func(env *models.Environment, args ...models.Object) models.Object {
+			for _, arg := range args {
+				fmt.Fprintln(env.IO().Err, arg.Inspect())
+			}
+
+			return models.NULL
+		},
+	},
+	"input": {
+		Func: func(env *models.Environment, args ...models.Object) models.Object {
+			for _, arg := range args {
+				fmt.Fprint(env.IO().Out, arg.Inspect())
+			}
+
+			return readLine(env)
+		},
+	},
+	"readline": {
+		Func: func(env *models.Environment, args ...models.Object) models.Object {
+			if len(args) != 0 {
+				return &models.Error{Message: fmt.Sprintf("WRONG NUMBER OF ARGUMENTS TO BUILT-IN FUNCTION `readline`. expected=0. got=%d", len(args))}
+			}
+
+			return readLine(env)
+		},
+	},
+	"assert": {
+		Func: func(env *models.Environment, args ...models.Object) models.Object {
+			if len(args) == 0 || len(args) >= 3 {
+				return &models.Error{Message: fmt.Sprintf("WRONG NUMBER OF ARGUMENTS TO BUILT-IN FUNCTION `assert`. expected=1. got=%d", len(args))}
+			}
+
+			if !models.IsTruthy(args[0]) {
+				return assertionFailed(args[1:], "condition is %s", args[0].Inspect())
+			}
+
+			return models.NULL
+		},
+	},
+	"assert_eq": {
+		Func: func(env *models.Environment, args ...models.Object) models.Object {
+			if len(args) <= 1 || len(args) >= 4 {
+				return &models.Error{Message: fmt.Sprintf("WRONG NUMBER OF ARGUMENTS TO BUILT-IN FUNCTION `assert_eq`. expected=2. got=%d", len(args))}
+			}
+
+			equal, err := Equal(args[0], args[1])
+			if err != nil {
+				return err
+			}
+
+			if !equal {
+				return assertionFailed(args[2:], "expected=%s. got=%s", args[1].Inspect(), args[0].Inspect())
+			}
+
+			return models.NULL
+		},
+	},
+	"assert_error": {
+		Func: func(env *models.Environment, args ...models.Object) models.Object {
+			if len(args) == 0 || len(args) >= 3 {
+				return &models.Error{Message: fmt.Sprintf("WRONG NUMBER OF ARGUMENTS TO BUILT-IN FUNCTION `assert_error`. expected=1. got=%d", len(args))}
+			}
+
+			switch args[0].(type) {
+			case *models.Function, *models.Builtin:
+			default:
+				return &models.Error{Message: fmt.Sprintf("ARGUMENT INVALID TYPE TO BUILT-IN FUNCTION `assert_error` (argument 0). expected=FUNCTION. got=%v", args[0].Type())}
+			}
+
+			result := ApplyFunction(args[0], []models.Object{}, env)
+			if result == nil {
+				// The body did not produce a value, such as an empty function.
+				result = models.NULL
+			}
+
+			err, ok := result.(*models.Error)
+			if !ok {
+				return assertionFailed(nil, "expected an error. got=%s", result.Inspect())
+			}
+
+			if len(args) == 2 {
+				expected, ok := args[1].(*models.String)
+				if !ok {
+					return &models.Error{Message: fmt.Sprintf("ARGUMENT INVALID TYPE TO BUILT-IN FUNCTION `assert_error` (argument 1). expected=STRING. got=%v", args[1].Type())}
+				}
+
+				if !strings.Contains(err.Message, expected.Value) {
+					return assertionFailed(nil, "expected an error containing %q. got=%q", expected.Value, err.Message)
+				}
 			}
 
 			return models.NULL
@@ -117,7 +546,7 @@ var Functions = map[string]*models.Builtin{
 			}
 
 			for _, v := range config.Pairs {
-				fmt.Println(v.Key.Inspect())
+				fmt.Fprintln(env.IO().Out, v.Key.Inspect())
 				fn := v.Value.(*models.Function)
 
 				handler := &HttpHandler{
@@ -24,3 +24,109 @@ func TestString_HashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestHash_Collisions(t *testing.T) {
+	a := &models.String{Value: "a"}
+	b := &models.String{Value: "b"}
+
+	// Pretend b collides with a by storing a under b's hash key.
+	hash := &models.Hash{Pairs: map[models.HashKey]models.HashPair{
+		b.HashKey(): {Key: a, Value: &models.Integer{Value: 1}},
+	}}
+
+	hash.Set(b, &models.Integer{Value: 2})
+
+	if len(hash.Pairs) != 2 {
+		t.Fatalf("colliding key overwrote the existing pair. got=%d pairs", len(hash.Pairs))
+	}
+
+	if pair := hash.Pairs[b.HashKey()]; pair.Key != a {
+		t.Errorf("colliding pair was moved. got key=%s", pair.Key.Inspect())
+	}
+
+	value, ok := hash.Get(b)
+	if !ok || value.(*models.Integer).Value != 2 {
+		t.Errorf("wrong value for colliding key. got=%v", value)
+	}
+
+	hash.Set(b, &models.Integer{Value: 3})
+	if len(hash.Pairs) != 2 {
+		t.Errorf("setting an existing colliding key added a pair. got=%d pairs", len(hash.Pairs))
+	}
+}
+
+func TestArray_HashKey(t *testing.T) {
+	frozen := func(elements ...models.Object) *models.Array {
+		return &models.Array{Elements: elements, Frozen: true}
+	}
+
+	one, two := &models.Integer{Value: 1}, &models.String{Value: "2"}
+
+	if frozen(one, two).HashKey() != frozen(one, two).HashKey() {
+		t.Errorf("equal frozen arrays have different hash keys")
+	}
+
+	if frozen(one, two).HashKey() == frozen(two, one).HashKey() {
+		t.Errorf("arrays in a different order have the same hash key")
+	}
+
+	if _, ok := models.HashKeyOf(&models.Array{Elements: []models.Object{one}}); ok {
+		t.Errorf("unfrozen array is hashable")
+	}
+
+	if _, ok := models.HashKeyOf(frozen(one, frozen(two))); !ok {
+		t.Errorf("frozen array of hashable values is not hashable")
+	}
+
+	if _, ok := models.HashKeyOf(frozen(one, &models.Array{})); ok {
+		t.Errorf("frozen array holding an unfrozen array is hashable")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	array := func(elements ...models.Object) *models.Array {
+		return &models.Array{Elements: elements}
+	}
+	integer := func(value int64) *models.Integer {
+		return &models.Integer{Value: value}
+	}
+
+	tests := []struct {
+		a, b     models.Object
+		expected bool
+	}{
+		{integer(1), integer(1), true},
+		{integer(1), &models.String{Value: "1"}, false},
+		{models.NULL, models.NULL, true},
+		{array(integer(1), array(integer(2))), array(integer(1), array(integer(2))), true},
+		{array(integer(1)), array(integer(1), integer(2)), false},
+		{&models.Function{}, &models.Function{}, false},
+	}
+
+	for i, tc := range tests {
+		if models.Equal(tc.a, tc.b) != tc.expected {
+			t.Errorf("test %d: Equal(%s, %s) should be %t", i, tc.a.Inspect(), tc.b.Inspect(), tc.expected)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b     models.Object
+		expected int
+		ok       bool
+	}{
+		{&models.Integer{Value: 1}, &models.Integer{Value: 2}, -1, true},
+		{&models.String{Value: "b"}, &models.String{Value: "a"}, 1, true},
+		{&models.Array{}, &models.Array{}, 0, true},
+		{&models.Hash{}, &models.Hash{}, 0, false},
+		{&models.Integer{Value: 1}, &models.String{Value: "1"}, 0, false},
+	}
+
+	for i, tc := range tests {
+		result, ok := models.Compare(tc.a, tc.b)
+		if result != tc.expected || ok != tc.ok {
+			t.Errorf("test %d: Compare(%s, %s) = %d, %t. expected=%d, %t", i, tc.a.Inspect(), tc.b.Inspect(), result, ok, tc.expected, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package lexer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// TemplatePart is a piece of an interpolated string: literal text with its
+// escape sequences applied, or the source of an embedded expression.
+type TemplatePart struct {
+	Value      string
+	Expression bool
+}
+
+// Unescape applies the escape sequences \n, \t, \r, \\, \", \$ and \u{...}
+// to the contents of a double quoted string.
+func Unescape(raw string) (string, error) {
+	if !strings.Contains(raw, `\`) {
+		return raw, nil
+	}
+
+	var out strings.Builder
+
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '\\' {
+			out.WriteByte(raw[i])
+			continue
+		}
+
+		i++
+		if i >= len(raw) {
+			return "", fmt.Errorf("unterminated escape sequence")
+		}
+
+		switch raw[i] {
+		case 'n':
+			out.WriteByte('\n')
+		case 't':
+			out.WriteByte('\t')
+		case 'r':
+			out.WriteByte('\r')
+		case '\\', '"', '$':
+			out.WriteByte(raw[i])
+		case 'u':
+			end := strings.IndexByte(raw[i:], '}')
+			if i+1 >= len(raw) || raw[i+1] != '{' || end == -1 {
+				return "", fmt.Errorf(`invalid unicode escape, expected \u{...}`)
+			}
+
+			digits := raw[i+2 : i+end]
+			code, err := strconv.ParseUint(digits, 16, 32)
+			if err != nil || !utf8.ValidRune(rune(code)) {
+				return "", fmt.Errorf(`invalid unicode escape \u{%s}`, digits)
+			}
+
+			out.WriteRune(rune(code))
+			i += end
+		default:
+			return "", fmt.Errorf(`unknown escape sequence \%c`, raw[i])
+		}
+	}
+
+	return out.String(), nil
+}
+
+// SplitTemplate splits the contents of an interpolated string into its
+// literal pieces and embedded expressions.
+func SplitTemplate(raw string) ([]TemplatePart, error) {
+	l := Create(`"` + raw + `"`)
+	parts := []TemplatePart{}
+	var err error
+
+	l.scanString(func(start, end int, expression bool) {
+		value := l.text(start, end)
+
+		if !expression {
+			if value == "" {
+				return
+			}
+
+			decoded, unescapeErr := Unescape(value)
+			if err == nil {
+				err = unescapeErr
+			}
+
+			value = decoded
+		}
+
+		parts = append(parts, TemplatePart{Value: value, Expression: expression})
+	})
+
+	return parts, err
+}
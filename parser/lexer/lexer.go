@@ -1,8 +1,12 @@
 package lexer
 
 import (
+	"bufio"
 	"fmt"
 	"github.com/kanersps/loop/parser/tokens"
+	"io"
+	"strings"
+	"unicode"
 )
 
 type Test struct {
@@ -14,35 +18,163 @@ func (t *Test) PrintTwo() {
 	fmt.Println(t.two)
 }
 
+// Lexer reads tokens from a stream of runes. Only the text of the token
+// being read is buffered, so input of any size can be lexed incrementally.
 type Lexer struct {
-	input        string
-	position     int
-	readPosition int
-	ch           byte
+	reader io.RuneReader
+	buffer strings.Builder
+	ch     rune
+	peek   rune
+	line   int
+	column int
+	err    error
+
+	retainComments bool
 }
 
 func Create(value string) *Lexer {
-	lexer := &Lexer{input: value}
+	return CreateFromReader(strings.NewReader(value))
+}
+
+func CreateFromReader(reader io.Reader) *Lexer {
+	runes, ok := reader.(io.RuneReader)
+	if !ok {
+		runes = bufio.NewReader(reader)
+	}
+
+	lexer := &Lexer{reader: runes, line: 1}
+	lexer.peek = lexer.readRune()
 	lexer.ReadCharacter()
 
 	return lexer
 }
 
+// Err returns the first error other than io.EOF returned by the reader. The
+// lexer reports EOF from that point on.
+func (l *Lexer) Err() error {
+	return l.err
+}
+
+func (l *Lexer) readRune() rune {
+	if l.err != nil {
+		return 0
+	}
+
+	ch, _, err := l.reader.ReadRune()
+	if err != nil {
+		if err != io.EOF {
+			l.err = err
+		}
+
+		return 0
+	}
+
+	return ch
+}
+
 func (l *Lexer) ReadCharacter() {
-	if l.readPosition >= len(l.input) {
-		l.ch = 0
-	} else {
-		l.ch = l.input[l.readPosition]
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+
+	if l.ch != 0 {
+		l.buffer.WriteRune(l.ch)
 	}
 
-	l.position = l.readPosition
-	l.readPosition++
+	l.ch = l.peek
+	l.peek = l.readRune()
+	l.column++
+}
+
+const (
+	UnterminatedString  = "unterminated string literal"
+	UnterminatedComment = "unterminated comment"
+)
+
+// RetainComments makes FindToken attach the comments in front of a token to
+// its Comments field instead of discarding them.
+func (l *Lexer) RetainComments() {
+	l.retainComments = true
 }
 
 func (l *Lexer) FindToken() tokens.Token {
-	var returnToken tokens.Token
+	line := l.line
+	var comments []tokens.Comment
+
+	for {
+		l.SkipWhitespace()
+		l.buffer.Reset()
+
+		if !l.atComment() {
+			break
+		}
+
+		comment := tokens.Comment{Line: l.line, Column: l.column, Newlines: l.line - line}
+		value, terminated := l.readComment()
+		comment.Value = value
+		line = l.line
+
+		if !terminated {
+			return tokens.Token{TokenType: tokens.Illegal, Value: UnterminatedComment, Line: comment.Line, Column: comment.Column}
+		}
 
-	l.SkipWhitespace()
+		if l.retainComments {
+			comments = append(comments, comment)
+		}
+	}
+
+	newlines, tokenLine, column := l.line-line, l.line, l.column
+
+	token := l.readToken()
+	token.Line = tokenLine
+	token.Column = column
+	token.Newlines = newlines
+	token.Comments = comments
+
+	return token
+}
+
+// atComment reports whether a line comment, a block comment or a shebang
+// line on the first line of the input starts at the current character.
+func (l *Lexer) atComment() bool {
+	if l.ch == '/' {
+		return l.peekChar() == '/' || l.peekChar() == '*'
+	}
+
+	return l.ch == '#' && l.peekChar() == '!' && l.line == 1 && l.column == 1
+}
+
+func (l *Lexer) readComment() (string, bool) {
+	position := l.offset()
+
+	if l.ch == '/' && l.peekChar() == '*' {
+		l.ReadCharacter()
+		l.ReadCharacter()
+
+		for l.ch != 0 && !(l.ch == '*' && l.peekChar() == '/') {
+			l.ReadCharacter()
+		}
+
+		if l.ch == 0 {
+			return l.text(position, l.offset()), false
+		}
+
+		l.ReadCharacter()
+		l.ReadCharacter()
+
+		return l.text(position, l.offset()), true
+	}
+
+	for l.ch != '\n' && l.ch != 0 {
+		l.ReadCharacter()
+	}
+
+	return strings.TrimRight(l.text(position, l.offset()), "\r"), true
+}
+
+func (l *Lexer) readToken() tokens.Token {
+	var returnToken tokens.Token
 
 	switch l.ch {
 	case '=':
@@ -50,6 +182,10 @@ func (l *Lexer) FindToken() tokens.Token {
 			ch := l.ch
 			l.ReadCharacter()
 			returnToken = tokens.Token{TokenType: tokens.EqualsInfix, Value: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '>' {
+			ch := l.ch
+			l.ReadCharacter()
+			returnToken = tokens.Token{TokenType: tokens.Arrow, Value: string(ch) + string(l.ch)}
 		} else {
 			returnToken = tokens.Token{TokenType: tokens.Equals, Value: string(l.ch)}
 		}
@@ -81,6 +217,18 @@ func (l *Lexer) FindToken() tokens.Token {
 		} else {
 			returnToken = tokens.Token{TokenType: tokens.Bang, Value: string(l.ch)}
 		}
+	case '&', '|':
+		if l.peekChar() == l.ch {
+			ch := l.ch
+			l.ReadCharacter()
+
+			returnToken = tokens.Token{TokenType: tokens.And, Value: string(ch) + string(l.ch)}
+			if ch == '|' {
+				returnToken.TokenType = tokens.Or
+			}
+		} else {
+			returnToken = tokens.Token{TokenType: tokens.Unknown, Value: string(l.ch)}
+		}
 	case '*':
 		returnToken = tokens.Token{TokenType: tokens.Asterisk, Value: string(l.ch)}
 	case '/':
@@ -91,11 +239,19 @@ func (l *Lexer) FindToken() tokens.Token {
 		returnToken = tokens.Token{TokenType: tokens.GreaterThan, Value: string(l.ch)}
 	case '-':
 		returnToken = tokens.Token{TokenType: tokens.Minus, Value: string(l.ch)}
-	case '"':
-		returnToken = tokens.Token{
-			TokenType: tokens.String,
-			Value:     l.readString(),
+	case '#':
+		if l.peekChar() == '{' {
+			l.ReadCharacter()
+			returnToken = tokens.Token{TokenType: tokens.SetBrace, Value: "#{"}
+		} else {
+			returnToken = tokens.Token{TokenType: tokens.Unknown, Value: string(l.ch)}
 		}
+	case '.':
+		returnToken = l.readDots()
+	case '"':
+		returnToken = l.readString()
+	case '`':
+		returnToken = l.readRawString()
 	case 0:
 		returnToken = tokens.Token{TokenType: tokens.EOF, Value: string(l.ch)}
 	default:
@@ -119,15 +275,35 @@ func (l *Lexer) FindToken() tokens.Token {
 	return returnToken
 }
 
+// readDots reads the "." of member access and the "..." of rest parameters
+// and spread arguments. Any other run of dots is illegal.
+func (l *Lexer) readDots() tokens.Token {
+	dots := 1
+
+	for dots < 3 && l.peekChar() == '.' {
+		l.ReadCharacter()
+		dots++
+	}
+
+	if dots == 1 {
+		return tokens.Token{TokenType: tokens.Dot, Value: "."}
+	}
+
+	if dots != 3 {
+		return tokens.Token{TokenType: tokens.Illegal, Value: "unexpected " + strings.Repeat(".", dots)}
+	}
+
+	return tokens.Token{TokenType: tokens.Ellipsis, Value: "..."}
+}
+
 func (l *Lexer) ReadIdentifier() string {
-	position := l.position
+	position := l.offset()
 
-	// TODO: Add support for numbers in identifiers
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || unicode.IsDigit(l.ch) {
 		l.ReadCharacter()
 	}
 
-	return l.input[position:l.position]
+	return l.text(position, l.offset())
 }
 
 func (l *Lexer) SkipWhitespace() {
@@ -137,38 +313,144 @@ func (l *Lexer) SkipWhitespace() {
 }
 
 func (l *Lexer) readNumber() string {
-	position := l.position
+	position := l.offset()
 	for isDigit(l.ch) {
 		l.ReadCharacter()
 	}
-	return l.input[position:l.position]
+	return l.text(position, l.offset())
+}
+
+func (l *Lexer) readString() tokens.Token {
+	position := l.offset() + 1
+	template, ok := l.scanString(func(start, end int, expression bool) {})
+
+	if !ok {
+		return tokens.Token{TokenType: tokens.Illegal, Value: UnterminatedString}
+	}
+
+	raw := l.text(position, l.offset())
+
+	if template {
+		return tokens.Token{TokenType: tokens.Template, Value: raw}
+	}
+
+	value, err := Unescape(raw)
+	if err != nil {
+		return tokens.Token{TokenType: tokens.Illegal, Value: err.Error()}
+	}
+
+	return tokens.Token{TokenType: tokens.String, Value: value}
+}
+
+// scanString moves from the opening quote of a string to its closing quote,
+// calling visit with the offsets of every literal piece and every embedded
+// ${...} expression. It reports whether the string contained an expression
+// and whether it was terminated.
+func (l *Lexer) scanString(visit func(start, end int, expression bool)) (bool, bool) {
+	template := false
+	start := l.offset() + 1
+
+	for {
+		l.ReadCharacter()
+
+		switch {
+		case l.ch == 0:
+			return template, false
+		case l.ch == '\\':
+			l.ReadCharacter()
+
+			if l.ch == 0 {
+				return template, false
+			}
+		case l.ch == '$' && l.peekChar() == '{':
+			template = true
+			visit(start, l.offset(), false)
+
+			l.ReadCharacter()
+			expressionStart := l.offset() + 1
+
+			if !l.skipInterpolation() {
+				return template, false
+			}
+
+			visit(expressionStart, l.offset(), true)
+			start = l.offset() + 1
+		case l.ch == '"':
+			visit(start, l.offset(), false)
+			return template, true
+		}
+	}
 }
 
-func (l *Lexer) readString() string {
-	position := l.position + 1
+// skipInterpolation moves from the opening brace of an embedded expression
+// to its closing brace, skipping over nested braces and strings.
+func (l *Lexer) skipInterpolation() bool {
+	depth := 1
+
 	for {
 		l.ReadCharacter()
 
-		if l.ch == '"' || l.ch == 0 {
-			break
+		switch l.ch {
+		case 0:
+			return false
+		case '{':
+			depth++
+		case '}':
+			depth--
+
+			if depth == 0 {
+				return true
+			}
+		case '"':
+			if _, ok := l.scanString(func(start, end int, expression bool) {}); !ok {
+				return false
+			}
+		case '`':
+			if l.readRawString().TokenType == tokens.Illegal {
+				return false
+			}
 		}
 	}
+}
+
+// readRawString reads a backtick delimited string, which may span lines and
+// has no escape sequences or interpolation.
+func (l *Lexer) readRawString() tokens.Token {
+	position := l.offset() + 1
+
+	for {
+		l.ReadCharacter()
+
+		if l.ch == 0 {
+			return tokens.Token{TokenType: tokens.Illegal, Value: UnterminatedString}
+		}
 
-	return l.input[position:l.position]
+		if l.ch == '`' {
+			return tokens.Token{TokenType: tokens.RawString, Value: l.text(position, l.offset())}
+		}
+	}
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
 
-func isDigit(ch byte) bool {
+// isDigit only accepts ASCII digits, since number literals are parsed with
+// strconv.
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
-		return 0
-	} else {
-		return l.input[l.readPosition]
-	}
+func (l *Lexer) peekChar() rune {
+	return l.peek
+}
+
+// offset is the position of the current character in the text buffered for
+// the token being read.
+func (l *Lexer) offset() int {
+	return l.buffer.Len()
+}
+
+func (l *Lexer) text(start, end int) string {
+	return l.buffer.String()[start:end]
 }
@@ -1,8 +1,12 @@
 package lexer
 
 import (
+	"errors"
 	"github.com/kanersps/loop/parser/tokens"
+	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestLexer_FindToken(tester *testing.T) {
@@ -102,3 +106,250 @@ func TestLexer_FindToken(tester *testing.T) {
 		}
 	}
 }
+
+func TestLexer_Positions(tester *testing.T) {
+	input := "var a = 1;\n\n  a + 2"
+
+	tests := []struct {
+		expectedValue    string
+		expectedLine     int
+		expectedColumn   int
+		expectedNewlines int
+	}{
+		{"var", 1, 1, 0},
+		{"a", 1, 5, 0},
+		{"=", 1, 7, 0},
+		{"1", 1, 9, 0},
+		{";", 1, 10, 0},
+		{"a", 3, 3, 2},
+		{"+", 3, 5, 0},
+		{"2", 3, 7, 0},
+	}
+
+	l := Create(input)
+
+	for i, test := range tests {
+		token := l.FindToken()
+
+		if token.Value != test.expectedValue {
+			tester.Fatalf("test (%d/%d) failed - wrong value: expected=%q, got=%q", i, len(tests), test.expectedValue, token.Value)
+		}
+
+		if token.Line != test.expectedLine || token.Column != test.expectedColumn {
+			tester.Fatalf("test (%d/%d) failed - wrong position: expected=%d:%d, got=%d:%d", i, len(tests), test.expectedLine, test.expectedColumn, token.Line, token.Column)
+		}
+
+		if token.Newlines != test.expectedNewlines {
+			tester.Fatalf("test (%d/%d) failed - wrong newlines: expected=%d, got=%d", i, len(tests), test.expectedNewlines, token.Newlines)
+		}
+	}
+}
+
+func TestLexer_Comments(tester *testing.T) {
+	input := `#!/usr/bin/env loop
+var a = 1; // one
+/* two
+   lines */ a / 2
+// unterminated at end`
+
+	tests := []struct {
+		expectedType  tokens.TokenType
+		expectedValue string
+		comments      []string
+	}{
+		{tokens.VariableDeclaration, "var", []string{"#!/usr/bin/env loop"}},
+		{tokens.Identifier, "a", nil},
+		{tokens.Equals, "=", nil},
+		{tokens.Number, "1", nil},
+		{tokens.SemiColon, ";", nil},
+		{tokens.Identifier, "a", []string{"// one", "/* two\n   lines */"}},
+		{tokens.Slash, "/", nil},
+		{tokens.Number, "2", nil},
+		{tokens.EOF, "\x00", []string{"// unterminated at end"}},
+	}
+
+	l := Create(input)
+	l.RetainComments()
+
+	for i, test := range tests {
+		token := l.FindToken()
+
+		if token.TokenType != test.expectedType || token.Value != test.expectedValue {
+			tester.Fatalf("test (%d/%d) failed - wrong token: expected=%v %q, got=%v %q", i, len(tests), test.expectedType, test.expectedValue, token.TokenType, token.Value)
+		}
+
+		if len(token.Comments) != len(test.comments) {
+			tester.Fatalf("test (%d/%d) failed - wrong comments: expected=%q, got=%+v", i, len(tests), test.comments, token.Comments)
+		}
+
+		for j, comment := range token.Comments {
+			if comment.Value != test.comments[j] {
+				tester.Fatalf("test (%d/%d) failed - wrong comment: expected=%q, got=%q", i, len(tests), test.comments[j], comment.Value)
+			}
+		}
+	}
+
+	l = Create("a // dropped\nb")
+	l.FindToken()
+
+	if token := l.FindToken(); token.Value != "b" || len(token.Comments) != 0 {
+		tester.Fatalf("comments should be discarded unless retained. got=%+v", token)
+	}
+}
+
+func TestLexer_Strings(tester *testing.T) {
+	tests := []struct {
+		input         string
+		expectedType  tokens.TokenType
+		expectedValue string
+	}{
+		{`"plain"`, tokens.String, "plain"},
+		{`"a\nb\tc\\d\"e"`, tokens.String, "a\nb\tc\\d\"e"},
+		{`"\u{48}\u{e9}\u{1F600}"`, tokens.String, "H\u00e9\U0001F600"},
+		{`"\${literal}"`, tokens.String, "${literal}"},
+		{"`raw \\n\nline`", tokens.RawString, "raw \\n\nline"},
+		{`"Hello ${name}!"`, tokens.Template, "Hello ${name}!"},
+		{`"${ {"a": "}"}["a"] }"`, tokens.Template, `${ {"a": "}"}["a"] }`},
+		{`"unterminated`, tokens.Illegal, UnterminatedString},
+		{"`unterminated", tokens.Illegal, UnterminatedString},
+		{`"${unterminated"`, tokens.Illegal, UnterminatedString},
+		{`"\q"`, tokens.Illegal, `unknown escape sequence \q`},
+		{`"\u{zz}"`, tokens.Illegal, `invalid unicode escape \u{zz}`},
+		{"/* open", tokens.Illegal, UnterminatedComment},
+	}
+
+	for i, test := range tests {
+		token := Create(test.input).FindToken()
+
+		if token.TokenType != test.expectedType || token.Value != test.expectedValue {
+			tester.Errorf("test (%d/%d) failed: expected=%v %q, got=%v %q", i, len(tests), test.expectedType, test.expectedValue, token.TokenType, token.Value)
+		}
+	}
+}
+
+func TestLexer_Operators(tester *testing.T) {
+	tests := []struct {
+		input         string
+		expectedType  tokens.TokenType
+		expectedValue string
+	}{
+		{"...rest", tokens.Ellipsis, "..."},
+		{"..", tokens.Illegal, "unexpected .."},
+		{"&&", tokens.And, "&&"},
+		{"||", tokens.Or, "||"},
+		{"&", tokens.Unknown, "&"},
+		{".name", tokens.Dot, "."},
+		{"#{1}", tokens.SetBrace, "#{"},
+		{"# {", tokens.Unknown, "#"},
+		{"in", tokens.In, "in"},
+		{"=>", tokens.Arrow, "=>"},
+		{"else", tokens.Else, "else"},
+		{"struct", tokens.Struct, "struct"},
+		{"for", tokens.For, "for"},
+		{"yield", tokens.Yield, "yield"},
+	}
+
+	for i, test := range tests {
+		token := Create(test.input).FindToken()
+
+		if token.TokenType != test.expectedType || token.Value != test.expectedValue {
+			tester.Errorf("test (%d/%d) failed: expected=%v %q, got=%v %q", i, len(tests), test.expectedType, test.expectedValue, token.TokenType, token.Value)
+		}
+	}
+}
+
+func TestSplitTemplate(tester *testing.T) {
+	parts, err := SplitTemplate(`Hi ${name}, \"${a + "}"}\"${b}`)
+	if err != nil {
+		tester.Fatal(err)
+	}
+
+	expected := []TemplatePart{
+		{Value: "Hi "},
+		{Value: "name", Expression: true},
+		{Value: ", \""},
+		{Value: `a + "}"`, Expression: true},
+		{Value: "\""},
+		{Value: "b", Expression: true},
+	}
+
+	if len(parts) != len(expected) {
+		tester.Fatalf("wrong number of parts. expected=%d, got=%+v", len(expected), parts)
+	}
+
+	for i, part := range parts {
+		if part != expected[i] {
+			tester.Errorf("part %d is wrong. expected=%+v, got=%+v", i, expected[i], part)
+		}
+	}
+}
+
+func TestLexer_Unicode(tester *testing.T) {
+	input := "var größe2 = \"héllo 世界\"; größe2 + 日本"
+
+	tests := []struct {
+		expectedType   tokens.TokenType
+		expectedValue  string
+		expectedColumn int
+	}{
+		{tokens.VariableDeclaration, "var", 1},
+		{tokens.Identifier, "größe2", 5},
+		{tokens.Equals, "=", 12},
+		{tokens.String, "héllo 世界", 14},
+		{tokens.SemiColon, ";", 24},
+		{tokens.Identifier, "größe2", 26},
+		{tokens.Plus, "+", 33},
+		{tokens.Identifier, "日本", 35},
+		{tokens.EOF, "\x00", 37},
+	}
+
+	l := Create(input)
+
+	for i, test := range tests {
+		token := l.FindToken()
+
+		if token.TokenType != test.expectedType || token.Value != test.expectedValue {
+			tester.Fatalf("test (%d/%d) failed - wrong token: expected=%v %q, got=%v %q", i, len(tests), test.expectedType, test.expectedValue, token.TokenType, token.Value)
+		}
+
+		if token.Column != test.expectedColumn {
+			tester.Fatalf("test (%d/%d) failed - wrong column: expected=%d, got=%d", i, len(tests), test.expectedColumn, token.Column)
+		}
+	}
+}
+
+func TestLexer_CreateFromReader(tester *testing.T) {
+	input := "var größe = \"a ${b}\" // c\n`raw`"
+
+	expected := Create(input)
+	l := CreateFromReader(iotest.OneByteReader(strings.NewReader(input)))
+
+	for i := 0; ; i++ {
+		want := expected.FindToken()
+		token := l.FindToken()
+
+		if token.TokenType != want.TokenType || token.Value != want.Value || token.Line != want.Line || token.Column != want.Column {
+			tester.Fatalf("token %d differs from the string lexer: expected=%+v, got=%+v", i, want, token)
+		}
+
+		if token.TokenType == tokens.EOF {
+			break
+		}
+	}
+
+	if l.Err() != nil {
+		tester.Fatalf("unexpected error: %s", l.Err())
+	}
+
+	failing := CreateFromReader(io.MultiReader(strings.NewReader("var a"), iotest.ErrReader(errors.New("read failed"))))
+
+	for _, expectedType := range []tokens.TokenType{tokens.VariableDeclaration, tokens.Identifier, tokens.EOF} {
+		if token := failing.FindToken(); token.TokenType != expectedType {
+			tester.Fatalf("wrong token after read error: expected=%v, got=%v", expectedType, token.TokenType)
+		}
+	}
+
+	if failing.Err() == nil || failing.Err().Error() != "read failed" {
+		tester.Fatalf("read error was not reported. got=%v", failing.Err())
+	}
+}
@@ -15,6 +15,8 @@ var variables = map[string]int{}
 const (
 	_ int = iota
 	LOWEST
+	OR
+	AND
 	EQUALS
 	LESSGREATER
 	SUM
@@ -25,8 +27,12 @@ const (
 )
 
 var precedences = map[tokens.TokenType]int{
+	tokens.Or:              OR,
+	tokens.And:             AND,
 	tokens.EqualsInfix:     EQUALS,
 	tokens.NotEquals:       EQUALS,
+	tokens.Is:              EQUALS,
+	tokens.In:              EQUALS,
 	tokens.LessThan:        LESSGREATER,
 	tokens.GreaterThan:     LESSGREATER,
 	tokens.Plus:            SUM,
@@ -35,6 +41,7 @@ var precedences = map[tokens.TokenType]int{
 	tokens.Asterisk:        PRODUCT,
 	tokens.LeftParentheses: CALL,
 	tokens.LeftBracket:     INDEX,
+	tokens.Dot:             INDEX,
 }
 
 func (p *Parser) peekPrecedence() int {
@@ -93,14 +100,17 @@ type (
 )
 
 type Parser struct {
-	l      *lexer.Lexer
-	errors []string
+	l           *lexer.Lexer
+	errors      []string
+	diagnostics []Error
 
 	curToken  tokens.Token
 	peekToken tokens.Token
 
 	prefixParseFns map[tokens.TokenType]prefixParseFn
 	infixParseFns  map[tokens.TokenType]infixParseFn
+
+	function *ast.FunctionLiteral // the function being parsed, nil at the top level
 }
 
 func Create(l *lexer.Lexer) *Parser {
@@ -119,11 +129,16 @@ func Create(l *lexer.Lexer) *Parser {
 	p.registerPrefix(tokens.False, p.parseBoolean)
 	p.registerPrefix(tokens.LeftParentheses, p.parseGroupedExpression)
 	p.registerPrefix(tokens.If, p.parseIfExpression)
+	p.registerPrefix(tokens.Match, p.parseMatchExpression)
 	p.registerPrefix(tokens.Function, p.parseFunctionLiteral)
 	p.registerPrefix(tokens.String, p.parseStringLiteral)
+	p.registerPrefix(tokens.RawString, p.parseStringLiteral)
+	p.registerPrefix(tokens.Template, p.parseTemplateLiteral)
 	p.registerPrefix(tokens.While, p.parseWhileLiteral)
+	p.registerPrefix(tokens.For, p.parseForLiteral)
 	p.registerPrefix(tokens.LeftBracket, p.parseArrayLiteral)
 	p.registerPrefix(tokens.LeftBrace, p.parseHashLiteral)
+	p.registerPrefix(tokens.SetBrace, p.parseSetLiteral)
 
 	// Infix parsers
 	p.infixParseFns = make(map[tokens.TokenType]infixParseFn)
@@ -133,10 +148,15 @@ func Create(l *lexer.Lexer) *Parser {
 	p.registerInfix(tokens.Asterisk, p.parseInfixExpression)
 	p.registerInfix(tokens.EqualsInfix, p.parseInfixExpression)
 	p.registerInfix(tokens.NotEquals, p.parseInfixExpression)
+	p.registerInfix(tokens.Is, p.parseInfixExpression)
+	p.registerInfix(tokens.In, p.parseInfixExpression)
 	p.registerInfix(tokens.LessThan, p.parseInfixExpression)
 	p.registerInfix(tokens.GreaterThan, p.parseInfixExpression)
+	p.registerInfix(tokens.And, p.parseInfixExpression)
+	p.registerInfix(tokens.Or, p.parseInfixExpression)
 	p.registerInfix(tokens.LeftParentheses, p.parseCallExpression)
 	p.registerInfix(tokens.LeftBracket, p.parseIndexExpression)
+	p.registerInfix(tokens.Dot, p.parseMemberExpression)
 
 	p.ExtractToken()
 	p.ExtractToken()
@@ -146,7 +166,7 @@ func Create(l *lexer.Lexer) *Parser {
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
-	exp.Arguments = p.parseCallArguments()
+	exp.Arguments = p.parseExpressionList(tokens.RightParentheses)
 	return exp
 }
 
@@ -167,6 +187,18 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: object}
+
+	if !p.expectPeek(tokens.Identifier) {
+		return nil
+	}
+
+	exp.Member = &ast.Identifier{Token: p.curToken, Value: p.curToken.Value}
+
+	return exp
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{
 		Token: p.curToken,
@@ -174,25 +206,81 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	}
 }
 
-func (p *Parser) parseCallArguments() []ast.Expression {
+func (p *Parser) parseTemplateLiteral() ast.Expression {
+	template := &ast.TemplateLiteral{Token: p.curToken}
+
+	parts, err := lexer.SplitTemplate(p.curToken.Value)
+	if err != nil {
+		p.addError(p.curToken, err.Error())
+		return nil
+	}
+
+	for _, part := range parts {
+		if !part.Expression {
+			template.Parts = append(template.Parts, &ast.StringLiteral{
+				Token: tokens.Token{TokenType: tokens.Template, Value: part.Value},
+				Value: part.Value,
+			})
+			continue
+		}
+
+		sub := Create(lexer.Create(part.Value))
+		expression := sub.parseExpression(LOWEST)
+
+		if len(sub.errors) == 0 && !sub.peekTokenIs(tokens.EOF) {
+			sub.errors = append(sub.errors, fmt.Sprintf("unexpected %q in interpolation ${%s}", sub.peekToken.Value, part.Value))
+		}
+
+		if len(sub.errors) != 0 {
+			// Positions inside the template are relative to the part, so
+			// report them at the template itself.
+			for _, msg := range sub.errors {
+				p.addError(template.Token, msg)
+			}
+			return nil
+		}
+
+		template.Parts = append(template.Parts, expression)
+	}
+
+	return template
+}
+
+// parseExpressionList parses the comma separated elements of a call, array or
+// set up to the closing token.
+func (p *Parser) parseExpressionList(end tokens.TokenType) []ast.Expression {
 	args := []ast.Expression{}
-	if p.peekTokenIs(tokens.RightParentheses) {
+	if p.peekTokenIs(end) {
 		p.ExtractToken()
 		return args
 	}
 	p.ExtractToken()
-	args = append(args, p.parseExpression(LOWEST))
+	args = append(args, p.parseArgument())
 	for p.peekTokenIs(tokens.Comma) {
 		p.ExtractToken()
 		p.ExtractToken()
-		args = append(args, p.parseExpression(LOWEST))
+		args = append(args, p.parseArgument())
 	}
-	if !p.expectPeek(tokens.RightParentheses) {
+	if !p.expectPeek(end) {
 		return nil
 	}
 	return args
 }
 
+// parseArgument parses an element of a list, which may spread an array:
+// f(...xs).
+func (p *Parser) parseArgument() ast.Expression {
+	if !p.curTokenIs(tokens.Ellipsis) {
+		return p.parseExpression(LOWEST)
+	}
+
+	spread := &ast.SpreadExpression{Token: p.curToken}
+	p.ExtractToken()
+	spread.Value = p.parseExpression(LOWEST)
+
+	return spread
+}
+
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
@@ -200,12 +288,22 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	for !p.peekTokenIs(tokens.RightBrace) {
 		p.ExtractToken()
 		key := p.parseExpression(LOWEST)
-		if !p.expectPeek(tokens.Colon) {
-			return nil
+
+		var value ast.Expression
+		if ident, ok := key.(*ast.Identifier); ok && (p.peekTokenIs(tokens.Comma) || p.peekTokenIs(tokens.RightBrace)) {
+			// {name} is short for {"name": name}.
+			key = &ast.StringLiteral{Token: ident.Token, Value: ident.Value}
+			value = ident
+		} else {
+			if !p.expectPeek(tokens.Colon) {
+				return nil
+			}
+			p.ExtractToken()
+			value = p.parseExpression(LOWEST)
 		}
-		p.ExtractToken()
-		value := p.parseExpression(LOWEST)
+
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 		if !p.peekTokenIs(tokens.RightBrace) && !p.expectPeek(tokens.Comma) {
 			return nil
 		}
@@ -218,31 +316,25 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 }
 
 func (p *Parser) parseArrayLiteral() ast.Expression {
-	array := &ast.ArrayLiteral{
-		Token:    p.curToken,
-		Elements: []ast.Expression{},
-	}
+	array := &ast.ArrayLiteral{Token: p.curToken}
 
-	if p.peekTokenIs(tokens.RightBracket) {
-		p.ExtractToken()
-		return array
+	array.Elements = p.parseExpressionList(tokens.RightBracket)
+	if array.Elements == nil {
+		return nil
 	}
 
-	p.ExtractToken()
-
-	array.Elements = append(array.Elements, p.parseExpression(LOWEST))
+	return array
+}
 
-	for p.peekTokenIs(tokens.Comma) {
-		p.ExtractToken()
-		p.ExtractToken()
-		array.Elements = append(array.Elements, p.parseExpression(LOWEST))
-	}
+func (p *Parser) parseSetLiteral() ast.Expression {
+	set := &ast.SetLiteral{Token: p.curToken}
 
-	if !p.expectPeek(tokens.RightBracket) {
+	set.Elements = p.parseExpressionList(tokens.RightBrace)
+	if set.Elements == nil {
 		return nil
 	}
 
-	return array
+	return set
 }
 
 func (p *Parser) parseWhileLiteral() ast.Expression {
@@ -267,38 +359,129 @@ func (p *Parser) parseWhileLiteral() ast.Expression {
 	return while
 }
 
+// parseForLiteral parses `for (pattern in iterable) { }`. The pattern binds
+// tighter than in, so it is parsed on its own before the iterable.
+func (p *Parser) parseForLiteral() ast.Expression {
+	loop := &ast.ForLiteral{Token: p.curToken}
+
+	if !p.expectPeek(tokens.LeftParentheses) {
+		return nil
+	}
+	p.ExtractToken()
+	loop.Pattern = p.parseExpression(EQUALS)
+	p.checkPattern(loop.Pattern, false)
+	if !p.expectPeek(tokens.In) {
+		return nil
+	}
+	p.ExtractToken()
+	loop.Iterable = p.parseExpression(LOWEST)
+	if !p.expectPeek(tokens.RightParentheses) {
+		return nil
+	}
+	if !p.expectPeek(tokens.LeftBrace) {
+		return nil
+	}
+
+	loop.Body = p.parseBlockStatement()
+
+	return loop
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.curToken}
+	if p.peekTokenIs(tokens.Identifier) {
+		p.ExtractToken()
+		lit.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Value}
+	}
 	if !p.expectPeek(tokens.LeftParentheses) {
 		return nil
 	}
-	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(lit) {
+		return nil
+	}
 	if !p.expectPeek(tokens.LeftBrace) {
 		return nil
 	}
+
+	outer := p.function
+	p.function = lit
 	lit.Body = p.parseBlockStatement()
+	p.function = outer
+
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// parseFunctionParameters parses `a, b = 2, ...rest)` into the parameters,
+// defaults and rest parameter of lit. Defaults stays nil when no parameter
+// has a default.
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
+	lit.Parameters = []*ast.Identifier{}
 	if p.peekTokenIs(tokens.RightParentheses) {
 		p.ExtractToken()
-		return identifiers
+		return true
 	}
-	p.ExtractToken()
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Value}
-	identifiers = append(identifiers, ident)
-	for p.peekTokenIs(tokens.Comma) {
+
+	defaults := []ast.Expression{}
+	hasDefaults := false
+	patterns := []ast.Expression{}
+	hasPatterns := false
+
+	for {
+		if p.peekTokenIs(tokens.Ellipsis) {
+			p.ExtractToken()
+			if !p.expectPeek(tokens.Identifier) {
+				return false
+			}
+			lit.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Value}
+			break
+		}
+
 		p.ExtractToken()
+
+		var pattern ast.Expression
+		if p.curTokenIs(tokens.LeftBracket) || p.curTokenIs(tokens.LeftBrace) {
+			lit.Parameters = append(lit.Parameters, &ast.Identifier{Token: p.curToken})
+			pattern = p.parsePattern(false)
+			hasPatterns = true
+		} else {
+			lit.Parameters = append(lit.Parameters, &ast.Identifier{Token: p.curToken, Value: p.curToken.Value})
+		}
+		patterns = append(patterns, pattern)
+
+		var value ast.Expression
+		if p.peekTokenIs(tokens.Equals) {
+			p.ExtractToken()
+			p.ExtractToken()
+			value = p.parseExpression(LOWEST)
+			hasDefaults = true
+		} else if hasDefaults {
+			// The earlier default could never apply, since every argument
+			// up to this parameter is required.
+			param := lit.Parameters[len(lit.Parameters)-1]
+			name := param.Value
+			if pattern != nil {
+				name = pattern.String()
+			}
+			p.addError(param.Token, fmt.Sprintf("parameter %s without a default follows one with a default", name))
+		}
+		defaults = append(defaults, value)
+
+		if !p.peekTokenIs(tokens.Comma) {
+			break
+		}
 		p.ExtractToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Value}
-		identifiers = append(identifiers, ident)
 	}
-	if !p.expectPeek(tokens.RightParentheses) {
-		return nil
+
+	if hasDefaults {
+		lit.Defaults = defaults
+	}
+
+	if hasPatterns {
+		lit.Patterns = patterns
 	}
-	return identifiers
+
+	// A rest parameter has to come last.
+	return p.expectPeek(tokens.RightParentheses)
 }
 
 func (p *Parser) parseIfExpression() ast.Expression {
@@ -318,6 +501,26 @@ func (p *Parser) parseIfExpression() ast.Expression {
 
 	if p.peekTokenIs(tokens.Else) {
 		p.ExtractToken()
+		expression.Else = p.curToken
+
+		if p.peekTokenIs(tokens.If) {
+			p.ExtractToken()
+
+			alternative := &ast.BlockStatement{Token: p.curToken}
+			nested, ok := p.parseIfExpression().(*ast.IfExpression)
+			if !ok {
+				return nil
+			}
+
+			alternative.Statements = []ast.Statement{&ast.ExpressionStatement{Token: nested.Token, Expression: nested}}
+			alternative.End = nested.Consequence.End
+			if nested.Alternative != nil {
+				alternative.End = nested.Alternative.End
+			}
+
+			expression.Alternative = alternative
+			return expression
+		}
 
 		if !p.expectPeek(tokens.LeftBrace) {
 			return nil
@@ -329,6 +532,112 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+// parseMatchExpression parses `match (value) { pattern => body, ... }`. A
+// body starting with { is a block, so an arm returning a hash literal has to
+// wrap it in parentheses. The comma after a block body may be left out.
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: p.curToken}
+	if !p.expectPeek(tokens.LeftParentheses) {
+		return nil
+	}
+	p.ExtractToken()
+	expression.Value = p.parseExpression(LOWEST)
+	if !p.expectPeek(tokens.RightParentheses) {
+		return nil
+	}
+	if !p.expectPeek(tokens.LeftBrace) {
+		return nil
+	}
+
+	for !p.peekTokenIs(tokens.RightBrace) && !p.peekTokenIs(tokens.EOF) {
+		p.ExtractToken()
+		arm := &ast.MatchArm{Pattern: p.parsePattern(true)}
+
+		if !p.expectPeek(tokens.Arrow) {
+			return nil
+		}
+		p.ExtractToken()
+
+		if p.curTokenIs(tokens.LeftBrace) {
+			arm.Body = p.parseBlockStatement()
+		} else {
+			arm.Body = &ast.ExpressionStatement{Token: p.curToken, Expression: p.parseExpression(LOWEST)}
+		}
+		expression.Arms = append(expression.Arms, arm)
+
+		if p.peekTokenIs(tokens.Comma) {
+			p.ExtractToken()
+			continue
+		}
+
+		if _, block := arm.Body.(*ast.BlockStatement); !block && !p.peekTokenIs(tokens.RightBrace) {
+			p.FindError(tokens.Comma)
+			return nil
+		}
+	}
+
+	if !p.expectPeek(tokens.RightBrace) {
+		return nil
+	}
+	expression.End = p.curToken
+
+	return expression
+}
+
+// parsePattern parses a pattern, which is written like the values it
+// matches: names to bind, _ to match anything, and arrays and hashes of
+// patterns. An array pattern may end in ...name to bind the remaining
+// elements, and {name} binds the value of the key "name". The patterns of
+// match arms may also contain literals, which the destructuring in var
+// statements and parameters cannot.
+func (p *Parser) parsePattern(literals bool) ast.Expression {
+	pattern := p.parseExpression(LOWEST)
+	p.checkPattern(pattern, literals)
+
+	return pattern
+}
+
+func (p *Parser) checkPattern(pattern ast.Expression, literals bool) {
+	switch pattern := pattern.(type) {
+	case nil, *ast.Identifier:
+		return
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean:
+		if literals {
+			return
+		}
+	case *ast.PrefixExpression:
+		if _, ok := pattern.Right.(*ast.IntegerLiteral); ok && pattern.Operator == "-" && literals {
+			return
+		}
+	case *ast.ArrayLiteral:
+		for idx, element := range pattern.Elements {
+			spread, ok := element.(*ast.SpreadExpression)
+			if !ok {
+				p.checkPattern(element, literals)
+				continue
+			}
+
+			if _, ok := spread.Value.(*ast.Identifier); !ok || idx != len(pattern.Elements)-1 {
+				p.addError(spread.Token, "... in a pattern must be followed by a name and come last")
+			}
+		}
+		return
+	case *ast.HashLiteral:
+		for _, key := range pattern.Keys {
+			switch key.(type) {
+			case *ast.StringLiteral, *ast.IntegerLiteral, *ast.Boolean:
+			default:
+				p.addError(ast.FirstToken(key), fmt.Sprintf("hash pattern key %s is not a literal", key.String()))
+			}
+
+			p.checkPattern(pattern.Pairs[key], literals)
+		}
+		return
+	}
+
+	p.addError(ast.FirstToken(pattern), fmt.Sprintf("%s is not a valid pattern", pattern.String()))
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
@@ -340,6 +649,7 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		}
 		p.ExtractToken()
 	}
+	block.End = p.curToken
 	return block
 }
 
@@ -378,12 +688,30 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// Error is a parse error together with the position of the token it was
+// reported at.
+type Error struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+// Diagnostics returns the same errors as Errors, with their positions.
+func (p *Parser) Diagnostics() []Error {
+	return p.diagnostics
+}
+
+func (p *Parser) addError(token tokens.Token, msg string) {
+	p.errors = append(p.errors, msg)
+	p.diagnostics = append(p.diagnostics, Error{Message: msg, Line: token.Line, Column: token.Column})
+}
+
 func (p *Parser) FindError(t tokens.TokenType) {
 	//expected := reflect.ValueOf(&book).Elem()
 
 	msg := fmt.Sprintf("Expected %v, got %v instead",
 		t, p.peekToken)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken, msg)
 }
 
 func (p *Parser) ExtractToken() {
@@ -402,10 +730,16 @@ func (p *Parser) ParseProgram() *ast.Program {
 		}
 		p.ExtractToken()
 	}
+	program.End = p.curToken
 	return program
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
+	if p.curTokenIs(tokens.Illegal) {
+		p.addError(p.curToken, p.curToken.Value)
+		return nil
+	}
+
 	prefix := p.prefixParseFns[p.curToken.TokenType]
 	if prefix == nil {
 		p.noPrefixParseFnError(p.curToken.TokenType)
@@ -430,30 +764,113 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.TokenType {
-	case tokens.VariableDeclaration:
+	case tokens.VariableDeclaration, tokens.Const:
 		return p.parseVarStatement()
 	case tokens.Return:
 		return p.parseReturnStatement()
+	case tokens.Function:
+		if p.peekTokenIs(tokens.Identifier) {
+			return p.parseFunctionStatement()
+		}
+		return p.parseExpressionStatement()
+	case tokens.Struct:
+		return p.parseStructStatement()
+	case tokens.Yield:
+		return p.parseYieldStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
-func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+func (p *Parser) parseExpressionStatement() ast.Statement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 	stmt.Expression = p.parseExpression(LOWEST)
+
+	if member, ok := stmt.Expression.(*ast.MemberExpression); ok && p.peekTokenIs(tokens.Equals) {
+		return p.parseAssignStatement(member)
+	}
+
 	if p.peekTokenIs(tokens.SemiColon) {
 		p.ExtractToken()
 	}
 	return stmt
 }
 
-func (p *Parser) parseVarStatement() ast.Statement {
-	stmt := &ast.VariableStatement{Token: p.curToken}
+// parseAssignStatement parses the value assigned to a field, `self.x = 1`.
+// Only fields can be assigned to; variables are declared again with var.
+func (p *Parser) parseAssignStatement(target *ast.MemberExpression) ast.Statement {
+	p.ExtractToken()
+	stmt := &ast.AssignStatement{Token: p.curToken, Target: target}
+
+	p.ExtractToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(tokens.SemiColon) {
+		p.ExtractToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseStructStatement() ast.Statement {
+	stmt := &ast.StructStatement{Token: p.curToken}
+
 	if !p.expectPeek(tokens.Identifier) {
 		return nil
 	}
+
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Value}
+
+	if !p.expectPeek(tokens.LeftBrace) {
+		return nil
+	}
+
+	stmt.Body = p.parseBlockStatement()
+
+	members := map[string]bool{}
+
+	for _, statement := range stmt.Body.Statements {
+		var name *ast.Identifier
+
+		switch statement := statement.(type) {
+		case *ast.VariableStatement:
+			if !statement.Constant() {
+				name = statement.Name
+			}
+		case *ast.FunctionStatement:
+			name = statement.Function.Name
+		}
+
+		if name == nil {
+			p.addError(ast.FirstToken(statement), fmt.Sprintf("struct %s can only declare fields with var and methods with func", stmt.Name.Value))
+			continue
+		}
+
+		if members[name.Value] {
+			p.addError(name.Token, fmt.Sprintf("struct %s declares %s more than once", stmt.Name.Value, name.Value))
+		}
+
+		members[name.Value] = true
+	}
+
+	if p.peekTokenIs(tokens.SemiColon) {
+		p.ExtractToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseVarStatement() ast.Statement {
+	stmt := &ast.VariableStatement{Token: p.curToken}
+	if p.peekTokenIs(tokens.LeftBracket) || p.peekTokenIs(tokens.LeftBrace) {
+		p.ExtractToken()
+		stmt.Pattern = p.parsePattern(false)
+	} else if !p.expectPeek(tokens.Identifier) {
+		return nil
+	} else {
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Value}
+	}
 	if !p.expectPeek(tokens.Equals) {
 		return nil
 	}
@@ -469,6 +886,22 @@ func (p *Parser) parseVarStatement() ast.Statement {
 	return stmt
 }
 
+func (p *Parser) parseFunctionStatement() ast.Statement {
+	stmt := &ast.FunctionStatement{Token: p.curToken}
+
+	function, ok := p.parseFunctionLiteral().(*ast.FunctionLiteral)
+	if !ok {
+		return nil
+	}
+	stmt.Function = function
+
+	if p.peekTokenIs(tokens.SemiColon) {
+		p.ExtractToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseReturnStatement() ast.Statement {
 	stmt := &ast.ReturnStatement{
 		Token: p.curToken,
@@ -485,12 +918,34 @@ func (p *Parser) parseReturnStatement() ast.Statement {
 	return stmt
 }
 
+// parseYieldStatement parses `yield value`, which turns the function it is
+// in into a generator.
+func (p *Parser) parseYieldStatement() ast.Statement {
+	stmt := &ast.YieldStatement{Token: p.curToken}
+
+	if p.function == nil {
+		p.addError(p.curToken, "yield outside of a function")
+	} else {
+		p.function.Generator = true
+	}
+
+	p.ExtractToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(tokens.SemiColon) {
+		p.ExtractToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 	value, err := strconv.ParseInt(p.curToken.Value, 0, 64)
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Value)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken, msg)
 		return nil
 	}
 	lit.Value = value
@@ -499,7 +954,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 func (p *Parser) noPrefixParseFnError(t tokens.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %v found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken, msg)
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
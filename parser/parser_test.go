@@ -317,6 +317,33 @@ func TestParser_InfixExpressions(t *testing.T) {
 	}
 }
 
+func TestParser_Sets(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{`#{}`, 0},
+		{`#{1, "two", 1 + 2}`, 3},
+		{`#{...xs, 4}`, 2},
+	}
+
+	for _, tt := range tests {
+		p := Create(lexer.Create(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		set, ok := stmt.Expression.(*ast.SetLiteral)
+		if !ok {
+			t.Fatalf("expression IS NOT ast.SetLiteral. got=%T", stmt.Expression)
+		}
+
+		if len(set.Elements) != tt.expected {
+			t.Errorf("len(set.Elements) not %d. got=%d", tt.expected, len(set.Elements))
+		}
+	}
+}
+
 func TestOperatorPrecedenceParsing(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -342,6 +369,18 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"!(true == true)",
 			"(!(true == true))",
 		},
+		{
+			"a is b == c < d",
+			"((a is b) == (c < d))",
+		},
+		{
+			"a || b && c == d",
+			"(a || (b && (c == d)))",
+		},
+		{
+			"!a && b || c",
+			"(((!a) && b) || c)",
+		},
 		{
 			"a + add(b * c) + d",
 			"((a + add((b * c))) + d)",
@@ -362,6 +401,14 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a in [1] && b in c",
+			"((a in [1]) && (b in c))",
+		},
+		{
+			"a + 1 in #{b, 2 * c} == true",
+			"(((a + 1) in #{b, (2 * c)}) == true)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -407,6 +454,177 @@ func TestParser_VarStatements(t *testing.T) {
 	}
 }
 
+func TestParser_ConstStatements(t *testing.T) {
+	p := Create(lexer.Create("const x = 5; var y = 1"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	for i, constant := range []bool{true, false} {
+		stmt, ok := program.Statements[i].(*ast.VariableStatement)
+		if !ok {
+			t.Fatalf("program.Statements[%d] is not ast.VariableStatement. got=%T", i, program.Statements[i])
+		}
+
+		if stmt.Constant() != constant {
+			t.Errorf("program.Statements[%d].Constant() should be %t", i, constant)
+		}
+	}
+}
+
+func TestParser_Structs(t *testing.T) {
+	p := Create(lexer.Create("struct Point { var x = 0; func move(dx) { self.x = self.x + dx } }; Point(1).move(2)"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.StructStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.StructStatement. got=%T", program.Statements[0])
+	}
+
+	if stmt.Name.Value != "Point" || len(stmt.Fields()) != 1 || len(stmt.Methods()) != 1 {
+		t.Fatalf("wrong struct. got=%s", stmt.String())
+	}
+
+	assign, ok := stmt.Methods()[0].Body.Statements[0].(*ast.AssignStatement)
+	if !ok {
+		t.Fatalf("method body is not ast.AssignStatement. got=%T", stmt.Methods()[0].Body.Statements[0])
+	}
+
+	if assign.String() != "self.x = (self.x + dx);" {
+		t.Errorf("wrong assignment. got=%q", assign.String())
+	}
+
+	if program.Statements[1].String() != "Point(1).move(2)" {
+		t.Errorf("wrong member call. got=%q", program.Statements[1].String())
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct P { 1 }", "struct P can only declare fields with var and methods with func"},
+		{"struct P { const x = 1 }", "struct P can only declare fields with var and methods with func"},
+		{"struct P { var x = 1; func x() {} }", "struct P declares x more than once"},
+		{"p.1", "Expected Identifier, got {Number 1 1 3 0 []} instead"},
+	}
+
+	for _, tt := range tests {
+		p := Create(lexer.Create(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("%q: expected error %q. got=%q", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestParser_ForLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for (x in xs) { x }", "for(x in xs) x"},
+		{"for ([k, v] in a + b) { k }", "for([k, v] in (a + b)) k"},
+		{"for (x in 1 in xs) {}", "for(x in (1 in xs)) "},
+	}
+
+	for _, tt := range tests {
+		p := Create(lexer.Create(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("%q: expected=%q. got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"for (1 in xs) {}", "1 is not a valid pattern"},
+		{"for (x) {}", "Expected In, got {RightParentheses ) 1 7 0 []} instead"},
+		{"yield 1", "yield outside of a function"},
+	}
+
+	for _, tt := range errors {
+		p := Create(lexer.Create(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("%q: expected error %q. got=%q", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestParser_Generators(t *testing.T) {
+	p := Create(lexer.Create("func gen() { yield 1; var f = func() { 2 } }; func inner() { func() { yield 1 } }"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	gen := program.Statements[0].(*ast.FunctionStatement).Function
+	if !gen.Generator {
+		t.Errorf("gen should be a generator")
+	}
+
+	if gen.Body.Statements[1].(*ast.VariableStatement).Value.(*ast.FunctionLiteral).Generator {
+		t.Errorf("a function inside a generator should not be a generator")
+	}
+
+	inner := program.Statements[1].(*ast.FunctionStatement).Function
+	if inner.Generator {
+		t.Errorf("a function containing a generator should not be a generator")
+	}
+
+	if !inner.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral).Generator {
+		t.Errorf("the nested function should be a generator")
+	}
+}
+
+func TestParser_Destructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"var [a, b, ...rest] = xs", "var [a, b, ...rest] = xs;"},
+		{"var {name, age} = person", "var {name:name, age:age} = person;"},
+		{`var {"first": [a, _]} = h`, "var {first:[a, _]} = h;"},
+		{"func([a, b], {name} = {}, c = 1) { a }", "func([a, b], {name:name} = {}, c = 1) a"},
+	}
+
+	for _, tt := range tests {
+		p := Create(lexer.Create(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("%q: expected=%q. got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	p := Create(lexer.Create("func(a, [b, c]) {}"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	fn := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if len(fn.Parameters) != 2 || len(fn.Patterns) != 2 || fn.Patterns[0] != nil || fn.Parameters[1].Value != "" {
+		t.Errorf("wrong parameters for destructured argument. got=%+v, %+v", fn.Parameters, fn.Patterns)
+	}
+}
+
+func TestParser_HashShorthand(t *testing.T) {
+	p := Create(lexer.Create(`{name, "age": 3}`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	hash := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.HashLiteral)
+	if len(hash.Keys) != 2 || !hash.IsShorthand(hash.Keys[0]) || hash.IsShorthand(hash.Keys[1]) {
+		t.Fatalf("wrong shorthand pairs. got=%s", hash.String())
+	}
+
+	testIdentifier(t, hash.Pairs[hash.Keys[0]], "name")
+}
+
 func TestParser_Strings(t *testing.T) {
 	input := `"testing two"`
 
@@ -429,6 +647,81 @@ func TestParser_Strings(t *testing.T) {
 	}
 }
 
+func TestParser_TemplateStrings(t *testing.T) {
+	input := `"Hello ${name}, you are ${age + 1}"`
+
+	l := lexer.Create(input)
+	p := Create(l)
+	program := p.ParseProgram()
+
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	template, ok := stmt.Expression.(*ast.TemplateLiteral)
+	if !ok {
+		t.Fatalf("Expression is not correct type. expected=ast.TemplateLiteral got=%T", stmt.Expression)
+	}
+
+	if len(template.Parts) != 4 {
+		t.Fatalf("template.Parts has wrong length. expected=4. got=%d", len(template.Parts))
+	}
+
+	testIdentifier(t, template.Parts[1], "name")
+	testInfixExpression(t, template.Parts[3], "age", "+", 1)
+
+	if template.String() != `"Hello ${name}, you are ${(age + 1)}"` {
+		t.Errorf("template.String() is wrong. got=%q", template.String())
+	}
+}
+
+func TestParser_StringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`var a = "open`, "unterminated string literal"},
+		{`"${}"`, "no prefix parse function for EOF found"},
+		{`"${a b}"`, `unexpected "b" in interpolation ${a b}`},
+		{`"\x"`, `unknown escape sequence \x`},
+	}
+
+	for _, tc := range tests {
+		p := Create(lexer.Create(tc.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tc.expected {
+			t.Errorf("wrong errors for %q. expected=%q. got=%q", tc.input, tc.expected, p.Errors())
+		}
+	}
+}
+
+func TestParser_Diagnostics(t *testing.T) {
+	tests := []struct {
+		input  string
+		line   int
+		column int
+	}{
+		{"var a = ;", 1, 9},
+		{"var a = 1\nvar b = (1", 2, 11},
+		{"var a = 1\n  \"${}\"", 2, 3},
+	}
+
+	for _, tc := range tests {
+		p := Create(lexer.Create(tc.input))
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) == 0 || len(diagnostics) != len(p.Errors()) {
+			t.Errorf("wrong diagnostics for %q. got=%+v", tc.input, diagnostics)
+			continue
+		}
+
+		if diagnostics[0].Line != tc.line || diagnostics[0].Column != tc.column {
+			t.Errorf("wrong position for %q. expected=%d:%d. got=%d:%d", tc.input, tc.line, tc.column, diagnostics[0].Line, diagnostics[0].Column)
+		}
+	}
+}
+
 func TestParser_Arrays(t *testing.T) {
 	input := `["one", 2, 1 + 2]`
 
@@ -601,6 +894,92 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestParser_ElseIf(t *testing.T) {
+	tests := []struct {
+		input      string
+		statements int
+		expected   string
+	}{
+		{"if (a) { 1 } else if (b) { 2 } else { 3 }", 1, "ifa 1else ifb 2else 3"},
+		{"if (a) { 1 } else if (b) { 2 }", 1, "ifa 1else ifb 2"},
+		// if and else used to share a token type, which made a second if
+		// look like an else.
+		{"if (a) { 1 }\nif (b) { 2 }", 2, "ifa 1ifb 2"},
+	}
+
+	for _, tt := range tests {
+		p := Create(lexer.Create(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != tt.statements {
+			t.Fatalf("%q: wrong number of statements. expected=%d. got=%d", tt.input, tt.statements, len(program.Statements))
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("%q: expected=%q. got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestParser_Match(t *testing.T) {
+	input := `match (x) {
+	1 => "one",
+	-1 => "minus one",
+	[a, ...rest] => { a },
+	{"type": t} => t,
+	_ => 0
+}`
+
+	p := Create(lexer.Create(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	match, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("expression IS NOT ast.MatchExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, match.Value, "x") {
+		return
+	}
+
+	if len(match.Arms) != 5 {
+		t.Fatalf("wrong number of arms. expected=5. got=%d", len(match.Arms))
+	}
+
+	if _, ok := match.Arms[2].Body.(*ast.BlockStatement); !ok {
+		t.Errorf("arm 2 body is not ast.BlockStatement. got=%T", match.Arms[2].Body)
+	}
+
+	if _, ok := match.Arms[3].Pattern.(*ast.HashLiteral); !ok {
+		t.Errorf("arm 3 pattern is not ast.HashLiteral. got=%T", match.Arms[3].Pattern)
+	}
+}
+
+func TestParser_MatchErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (x) { a + 1 => 1 }", "(a + 1) is not a valid pattern"},
+		{"match (x) { [...rest, a] => 1 }", "... in a pattern must be followed by a name and come last"},
+		{"match (x) { {k: 1} => 1 }", "hash pattern key k is not a literal"},
+		{"var [a, 1] = x", "1 is not a valid pattern"},
+		{"func([a + b]) {}", "(a + b) is not a valid pattern"},
+	}
+
+	for _, tt := range tests {
+		p := Create(lexer.Create(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("%q: expected error %q. got=%q", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `func(x, y) { x + y; }`
 	l := lexer.Create(input)
@@ -665,6 +1044,89 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestParser_DefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"func(a, b = 2) {}", "func(a, b = 2) "},
+		{"func(a = 1 + 2, b = a) {}", "func(a = (1 + 2), b = a) "},
+		{"func(...rest) {}", "func(...rest) "},
+		{"func(first, second = first, ...rest) {}", "func(first, second = first, ...rest) "},
+		{"f(...xs, 1, ...[2])", "f(...xs, 1, ...[2])"},
+	}
+
+	for _, tc := range tests {
+		p := Create(lexer.Create(tc.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tc.expected {
+			t.Errorf("wrong program for %q. expected=%q. got=%q", tc.input, tc.expected, program.String())
+		}
+	}
+
+	function := Create(lexer.Create("func(a, b) {}")).ParseProgram().Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if function.Defaults != nil || function.Rest != nil {
+		t.Errorf("plain parameters should have no defaults or rest parameter")
+	}
+
+	for _, input := range []string{"func(...rest, a) {}", "func(...) {}", "f(..xs)"} {
+		p := Create(lexer.Create(input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected errors for %q", input)
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"func f(a = 1, b) {}", "parameter b without a default follows one with a default"},
+		{"func(a, b = 1, [c]) {}", "parameter [c] without a default follows one with a default"},
+	}
+
+	for _, tt := range errorTests {
+		p := Create(lexer.Create(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("%q: expected error %q. got=%q", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestParser_FunctionStatements(t *testing.T) {
+	p := Create(lexer.Create("func add(a, b) { a + b }; var sub = func minus(a) { -a }; func() {}"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("wrong number of statements. got=%d", len(program.Statements))
+	}
+
+	declaration, ok := program.Statements[0].(*ast.FunctionStatement)
+	if !ok {
+		t.Fatalf("statement is not ast.FunctionStatement. got=%T", program.Statements[0])
+	}
+
+	if declaration.Function.Name.Value != "add" || len(declaration.Function.Parameters) != 2 {
+		t.Errorf("wrong declaration. got=%s", declaration.String())
+	}
+
+	named := program.Statements[1].(*ast.VariableStatement).Value.(*ast.FunctionLiteral)
+	if named.Name == nil || named.Name.Value != "minus" {
+		t.Errorf("function literal should be named minus. got=%s", named.String())
+	}
+
+	anonymous := program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if anonymous.Name != nil {
+		t.Errorf("function literal should be anonymous. got=%s", anonymous.String())
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 	l := lexer.Create(input)
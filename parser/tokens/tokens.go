@@ -1,10 +1,28 @@
 package tokens
 
+import "fmt"
+
 type TokenType int64
 
 type Token struct {
 	TokenType TokenType
 	Value     string
+	Line      int
+	Column    int
+	// Newlines is the number of line breaks between the previous token and
+	// this one, which lets the formatter keep blank lines.
+	Newlines int
+	// Comments are the comments between the previous token and this one. They
+	// are only filled in when the lexer is asked to retain them.
+	Comments []Comment
+}
+
+type Comment struct {
+	// Value is the full comment text including its // or /* */ markers.
+	Value    string
+	Line     int
+	Column   int
+	Newlines int
 }
 
 var keywords = map[string]TokenType{
@@ -16,6 +34,74 @@ var keywords = map[string]TokenType{
 	"if":     If,
 	"else":   Else,
 	"while":  While,
+	"is":     Is,
+	"in":     In,
+	"match":  Match,
+	"const":  Const,
+	"struct": Struct,
+	"for":    For,
+	"yield":  Yield,
+}
+
+var names = map[TokenType]string{
+	Unknown:             "Unknown",
+	Number:              "Number",
+	Operator:            "Operator",
+	VariableDeclaration: "VariableDeclaration",
+	Identifier:          "Identifier",
+	Equals:              "Equals",
+	Print:               "Print",
+	SemiColon:           "SemiColon",
+	LeftParentheses:     "LeftParentheses",
+	RightParentheses:    "RightParentheses",
+	Comma:               "Comma",
+	Plus:                "Plus",
+	LeftBrace:           "LeftBrace",
+	RightBrace:          "RightBrace",
+	EOF:                 "EOF",
+	Bang:                "Bang",
+	Asterisk:            "Asterisk",
+	Slash:               "Slash",
+	LessThan:            "LessThan",
+	GreaterThan:         "GreaterThan",
+	Minus:               "Minus",
+	Function:            "Function",
+	Return:              "Return",
+	NotEquals:           "NotEquals",
+	EqualsInfix:         "EqualsInfix",
+	True:                "True",
+	False:               "False",
+	If:                  "If",
+	Else:                "Else",
+	String:              "String",
+	While:               "While",
+	LeftBracket:         "LeftBracket",
+	RightBracket:        "RightBracket",
+	Colon:               "Colon",
+	Illegal:             "Illegal",
+	Template:            "Template",
+	RawString:           "RawString",
+	Ellipsis:            "Ellipsis",
+	And:                 "And",
+	Or:                  "Or",
+	Is:                  "Is",
+	SetBrace:            "SetBrace",
+	In:                  "In",
+	Match:               "Match",
+	Arrow:               "Arrow",
+	Const:               "Const",
+	Dot:                 "Dot",
+	Struct:              "Struct",
+	For:                 "For",
+	Yield:               "Yield",
+}
+
+func (t TokenType) String() string {
+	if name, ok := names[t]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("TokenType(%d)", int64(t))
 }
 
 func FindKeyword(keyword string) TokenType {
@@ -55,10 +141,26 @@ const (
 	True                TokenType = 25
 	False               TokenType = 26
 	If                  TokenType = 27
-	Else                TokenType = 27
 	String              TokenType = 28
 	While               TokenType = 29
 	LeftBracket         TokenType = 30
 	RightBracket        TokenType = 31
 	Colon               TokenType = 32
+	Illegal             TokenType = 33
+	Template            TokenType = 34
+	RawString           TokenType = 35
+	Ellipsis            TokenType = 36
+	And                 TokenType = 37
+	Or                  TokenType = 38
+	Is                  TokenType = 39
+	SetBrace            TokenType = 40
+	In                  TokenType = 41
+	Else                TokenType = 42
+	Match               TokenType = 43
+	Arrow               TokenType = 44
+	Const               TokenType = 45
+	Dot                 TokenType = 46
+	Struct              TokenType = 47
+	For                 TokenType = 48
+	Yield               TokenType = 49
 )
@@ -0,0 +1,122 @@
+package repl
+
+import (
+	"fmt"
+	"github.com/kanersps/loop/ast"
+	"github.com/kanersps/loop/evaluator"
+	"github.com/kanersps/loop/parser/lexer"
+	"github.com/kanersps/loop/parser/tokens"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+type command struct {
+	usage       string
+	description string
+	run         func(s *session, arg string)
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"ast":    {":ast <code>", "print the syntax tree of code", (*session).printAST},
+		"tokens": {":tokens <code>", "print the tokens of code", (*session).printTokens},
+		"env":    {":env", "list the current bindings and their types", (*session).printEnv},
+		"load":   {":load <file>", "run a file in the current session", (*session).load},
+		"reset":  {":reset", "discard all bindings", func(s *session, arg string) { s.reset() }},
+		"time":   {":time <code>", "run code and report how long it took", (*session).time},
+		"help":   {":help", "list the available commands", (*session).help},
+	}
+}
+
+func (s *session) runCommand(line string) {
+	name := strings.TrimPrefix(line, ":")
+	arg := ""
+
+	if idx := strings.IndexAny(name, " \t\n"); idx != -1 {
+		name, arg = name[:idx], strings.TrimSpace(name[idx:])
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(s.output, "unknown command :%s, see :help\n", name)
+		return
+	}
+
+	cmd.run(s, arg)
+}
+
+func (s *session) printAST(arg string) {
+	if program := s.parse(arg); program != nil {
+		ast.Fprint(s.output, program)
+	}
+}
+
+func (s *session) printTokens(arg string) {
+	l := lexer.Create(arg)
+
+	for token := l.FindToken(); token.TokenType != tokens.EOF; token = l.FindToken() {
+		fmt.Fprintf(s.output, "%-20s %q\n", token.TokenType, token.Value)
+	}
+}
+
+func (s *session) printEnv(arg string) {
+	names := []string{}
+	for name := range s.env.Store {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(s.output, "%-20s %-10s %s\n", name, s.env.Store[name].Type(), firstLine(s.env.Store[name].Inspect()))
+	}
+}
+
+func (s *session) load(arg string) {
+	input, err := os.ReadFile(arg)
+	if err != nil {
+		fmt.Fprintln(s.output, err)
+		return
+	}
+
+	s.run(string(input))
+}
+
+func (s *session) time(arg string) {
+	program := s.parse(arg)
+	if program == nil {
+		return
+	}
+
+	start := time.Now()
+	evaluated := evaluator.Eval(program, s.env)
+	elapsed := time.Since(start)
+
+	s.print(evaluated)
+	fmt.Fprintf(s.output, "took %s\n", elapsed)
+}
+
+func (s *session) help(arg string) {
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(s.output, "%-20s %s\n", commands[name].usage, commands[name].description)
+	}
+}
+
+func firstLine(value string) string {
+	if idx := strings.IndexByte(value, '\n'); idx != -1 {
+		return value[:idx] + " ..."
+	}
+
+	return value
+}
@@ -0,0 +1,283 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const maxHistory = 1000
+
+var errInterrupted = errors.New("interrupted")
+
+// editor is a minimal line editor for interactive terminals. It supports
+// cursor movement, history navigation and tab completion.
+type editor struct {
+	fd          int
+	in          *bufio.Reader
+	out         io.Writer
+	history     []string
+	historyPath string
+	complete    func(prefix string) []string
+}
+
+func newEditor(file *os.File, in *bufio.Reader, out io.Writer, complete func(string) []string) (*editor, bool) {
+	fd := int(file.Fd())
+	if !isTerminal(fd) {
+		return nil, false
+	}
+
+	e := &editor{fd: fd, in: in, out: out, complete: complete}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		e.historyPath = filepath.Join(home, ".loop_history")
+		e.loadHistory()
+	}
+
+	return e, true
+}
+
+func (e *editor) loadHistory() {
+	data, err := os.ReadFile(e.historyPath)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+}
+
+func (e *editor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[1:]
+	}
+
+	if e.historyPath == "" {
+		return
+	}
+
+	file, err := os.OpenFile(e.historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	fmt.Fprintln(file, line)
+}
+
+func (e *editor) ReadLine(prompt string) (string, error) {
+	state, err := makeRaw(e.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore(e.fd, state)
+
+	line := []rune{}
+	pos := 0
+	historyIdx := len(e.history)
+	draft := ""
+
+	redraw := func() {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(line))
+		if back := len(line) - pos; back > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", back)
+		}
+	}
+
+	setLine := func(value string) {
+		line = []rune(value)
+		pos = len(line)
+		redraw()
+	}
+
+	io.WriteString(e.out, prompt)
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			io.WriteString(e.out, "\r\n")
+			e.addHistory(string(line))
+			return string(line), nil
+		case 3: // Ctrl-C
+			io.WriteString(e.out, "^C\r\n")
+			return "", errInterrupted
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+
+			if pos < len(line) {
+				line = append(line[:pos], line[pos+1:]...)
+				redraw()
+			}
+		case 127, 8: // Backspace
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+				redraw()
+			}
+		case 1: // Ctrl-A
+			pos = 0
+			redraw()
+		case 5: // Ctrl-E
+			pos = len(line)
+			redraw()
+		case 11: // Ctrl-K
+			line = line[:pos]
+			redraw()
+		case 21: // Ctrl-U
+			line = line[pos:]
+			pos = 0
+			redraw()
+		case '\t':
+			line, pos = e.completeWord(line, pos)
+			redraw()
+		case 27: // Escape sequence
+			key := e.readEscape()
+
+			switch key {
+			case "[D":
+				if pos > 0 {
+					pos--
+				}
+			case "[C":
+				if pos < len(line) {
+					pos++
+				}
+			case "[H", "OH", "[1~":
+				pos = 0
+			case "[F", "OF", "[4~":
+				pos = len(line)
+			case "[3~":
+				if pos < len(line) {
+					line = append(line[:pos], line[pos+1:]...)
+				}
+			case "[A":
+				if historyIdx > 0 {
+					if historyIdx == len(e.history) {
+						draft = string(line)
+					}
+
+					historyIdx--
+					setLine(e.history[historyIdx])
+				}
+				continue
+			case "[B":
+				if historyIdx < len(e.history) {
+					historyIdx++
+
+					if historyIdx == len(e.history) {
+						setLine(draft)
+					} else {
+						setLine(e.history[historyIdx])
+					}
+				}
+				continue
+			}
+
+			redraw()
+		default:
+			if unicode.IsPrint(r) {
+				line = append(line[:pos], append([]rune{r}, line[pos:]...)...)
+				pos++
+				redraw()
+			}
+		}
+	}
+}
+
+func (e *editor) readEscape() string {
+	first, _, err := e.in.ReadRune()
+	if err != nil {
+		return ""
+	}
+
+	key := string(first)
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return key
+		}
+
+		key += string(r)
+
+		// Sequences end with a letter or a tilde, e.g. "[A" or "[3~".
+		if unicode.IsLetter(r) || r == '~' {
+			return key
+		}
+	}
+}
+
+// completeWord completes the identifier in front of the cursor. When the
+// candidates share no longer prefix they are listed below the prompt.
+func (e *editor) completeWord(line []rune, pos int) ([]rune, int) {
+	start := pos
+	for start > 0 && isIdentifierRune(line[start-1]) {
+		start--
+	}
+
+	prefix := string(line[start:pos])
+	candidates := e.complete(prefix)
+
+	if len(candidates) == 0 {
+		return line, pos
+	}
+
+	common := commonPrefix(candidates)
+
+	if len(candidates) > 1 && common == prefix {
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+		return line, pos
+	}
+
+	completion := []rune(common[len(prefix):])
+
+	rest := append([]rune{}, line[pos:]...)
+	line = append(append(line[:pos], completion...), rest...)
+
+	return line, pos + len(completion)
+}
+
+func isIdentifierRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// commonPrefix returns the longest prefix shared by values, shortened a
+// whole rune at a time so it stays valid UTF-8.
+func commonPrefix(values []string) string {
+	prefix := values[0]
+
+	for _, value := range values[1:] {
+		for !strings.HasPrefix(value, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+
+	return prefix
+}
@@ -0,0 +1,85 @@
+package repl
+
+import (
+	"bufio"
+	"github.com/kanersps/loop/parser/lexer"
+	"github.com/kanersps/loop/parser/tokens"
+	"io"
+	"strings"
+)
+
+const (
+	prompt             = ">> "
+	continuationPrompt = ".. "
+)
+
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// plainReader is used when the input is not an interactive terminal, e.g.
+// when a script is piped into the REPL.
+type plainReader struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (r *plainReader) ReadLine(prompt string) (string, error) {
+	io.WriteString(r.out, prompt)
+	line, err := r.in.ReadString('\n')
+
+	if err != nil && line == "" {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readEntry keeps reading lines until they form a complete piece of source,
+// switching to the continuation prompt after the first line.
+func readEntry(r lineReader) (string, error) {
+	lines := []string{}
+	current := prompt
+
+	for {
+		line, err := r.ReadLine(current)
+		if err != nil {
+			if err == io.EOF && len(lines) != 0 {
+				return strings.Join(lines, "\n"), nil
+			}
+
+			return "", err
+		}
+
+		lines = append(lines, line)
+		source := strings.Join(lines, "\n")
+
+		if isComplete(source) {
+			return source, nil
+		}
+
+		current = continuationPrompt
+	}
+}
+
+// isComplete reports whether source has no unclosed braces, parentheses,
+// brackets, strings or comments.
+func isComplete(source string) bool {
+	depth := 0
+	l := lexer.Create(source)
+
+	for token := l.FindToken(); token.TokenType != tokens.EOF; token = l.FindToken() {
+		switch token.TokenType {
+		case tokens.LeftBrace, tokens.LeftParentheses, tokens.LeftBracket:
+			depth++
+		case tokens.RightBrace, tokens.RightParentheses, tokens.RightBracket:
+			depth--
+		case tokens.Illegal:
+			if token.Value == lexer.UnterminatedString || token.Value == lexer.UnterminatedComment {
+				return false
+			}
+		}
+	}
+
+	return depth <= 0
+}
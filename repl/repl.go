@@ -2,45 +2,123 @@ package repl
 
 import (
 	"bufio"
-	"fmt"
+	"github.com/kanersps/loop/ast"
 	"github.com/kanersps/loop/evaluator"
+	"github.com/kanersps/loop/models"
 	"github.com/kanersps/loop/object"
+	"github.com/kanersps/loop/object/builtins"
 	"github.com/kanersps/loop/parser"
 	"github.com/kanersps/loop/parser/lexer"
 	"io"
+	"os"
+	"sort"
+	"strings"
 )
 
+type session struct {
+	env     *models.Environment
+	streams *models.Streams
+	output  io.Writer
+}
+
 func Console(input io.Reader, output io.Writer) {
-	scanner := bufio.NewScanner(input)
-	env := object.NewEnvironment()
+	// The session and the scripts it runs share one reader, so a call to
+	// input() consumes the next line typed at the prompt.
+	reader := bufio.NewReader(input)
+	s := &session{
+		streams: models.NewStreams(reader, output, os.Stderr),
+		output:  output,
+	}
+	s.reset()
+
+	var lines lineReader = &plainReader{in: reader, out: output}
+
+	if file, ok := input.(*os.File); ok {
+		if e, ok := newEditor(file, reader, output, s.complete); ok {
+			lines = e
+		}
+	}
 
 	for {
-		fmt.Printf(">> ")
-		scanned := scanner.Scan()
+		source, err := readEntry(lines)
 
-		if !scanned {
+		if err == errInterrupted {
+			continue
+		}
+
+		if err != nil {
 			return
 		}
 
-		line := scanner.Text()
+		if strings.HasPrefix(strings.TrimSpace(source), ":") {
+			s.runCommand(strings.TrimSpace(source))
+			continue
+		}
+
+		s.run(source)
+	}
+}
 
-		l := lexer.Create(line)
-		parser := parser.Create(l)
-		program := parser.ParseProgram()
+func (s *session) reset() {
+	s.env = object.NewEnvironment()
+	s.env.Streams = s.streams
+}
 
-		if len(parser.Errors()) != 0 {
-			printParserErrors(output, parser.Errors())
-			continue
+// parse returns nil after printing the parser errors if source is invalid.
+func (s *session) parse(source string) ast.Node {
+	l := lexer.Create(source)
+	p := parser.Create(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.output, p.Errors())
+		return nil
+	}
+
+	return program
+}
+
+func (s *session) run(source string) {
+	program := s.parse(source)
+	if program == nil {
+		return
+	}
+
+	s.print(evaluator.Eval(program, s.env))
+}
+
+func (s *session) print(evaluated models.Object) {
+	if evaluated != nil {
+		io.WriteString(s.output, evaluated.Inspect())
+		io.WriteString(s.output, "\n")
+	}
+}
+
+// complete returns the names bound in the session and the builtins that
+// start with prefix, sorted and without duplicates.
+func (s *session) complete(prefix string) []string {
+	seen := map[string]bool{}
+	candidates := []string{}
+
+	add := func(name string) {
+		if strings.HasPrefix(name, prefix) && !seen[name] {
+			seen[name] = true
+			candidates = append(candidates, name)
 		}
-		evaluated := evaluator.Eval(program, env)
+	}
 
-		if evaluated != nil {
-			io.WriteString(output, evaluated.Inspect())
-			io.WriteString(output, "\n")
+	for scope := s.env; scope != nil; scope = scope.Outer {
+		for name := range scope.Store {
+			add(name)
 		}
+	}
 
-		//program.PrintAST()
+	for name := range builtins.Functions {
+		add(name)
 	}
+
+	sort.Strings(candidates)
+	return candidates
 }
 
 func printParserErrors(out io.Writer, errors []string) {
@@ -0,0 +1,101 @@
+package repl
+
+import (
+	"bytes"
+	"github.com/kanersps/loop/models"
+	"github.com/kanersps/loop/object"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIsComplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"var test = 5;", true},
+		{"var add = func(a, b) {", false},
+		{"var add = func(a, b) {\n\treturn a + b\n}", true},
+		{"add(1,", false},
+		{"[1, 2,", false},
+		{`"unterminated`, false},
+		{`"{"`, true},
+		{"`raw\nstring", false},
+		{"/* open comment", false},
+		{"// {", true},
+		{"}", true},
+	}
+
+	for _, tc := range tests {
+		if isComplete(tc.input) != tc.expected {
+			t.Errorf("isComplete(%q) should be %t", tc.input, tc.expected)
+		}
+	}
+}
+
+func TestConsole_MultiLine(t *testing.T) {
+	input := "var add = func(a, b) {\n\treturn a + b\n}\nadd(1,\n2)\n"
+	var output bytes.Buffer
+
+	Console(strings.NewReader(input), &output)
+
+	expected := ">> .. .. >> .. 3\n>> "
+	if output.String() != expected {
+		t.Errorf("wrong output. expected=%q. got=%q", expected, output.String())
+	}
+}
+
+func TestCompleter(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Set("length", &models.Integer{Value: 1})
+	env.Set("other", &models.Integer{Value: 2})
+
+	s := &session{env: object.NewEnclosedEnvironment(env)}
+	candidates := s.complete("le")
+
+	if !reflect.DeepEqual(candidates, []string{"len", "length"}) {
+		t.Errorf("wrong candidates. got=%v", candidates)
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		values   []string
+		expected string
+	}{
+		{[]string{"len", "length"}, "len"},
+		{[]string{"größe", "grün"}, "gr"},
+		{[]string{"é", "è"}, ""},
+	}
+
+	for _, tc := range tests {
+		if prefix := commonPrefix(tc.values); prefix != tc.expected {
+			t.Errorf("commonPrefix(%q) is wrong. expected=%q. got=%q", tc.values, tc.expected, prefix)
+		}
+	}
+}
+
+func TestConsole_Commands(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{":tokens var x = 1", "VariableDeclaration  \"var\"\nIdentifier           \"x\"\nEquals               \"=\"\nNumber               \"1\"\n"},
+		{":ast -x", "Program\n  Statements: (1)\n    0: ExpressionStatement\n      Expression: PrefixExpression\n        Operator: \"-\"\n        Right: Identifier\n          Value: \"x\"\n"},
+		{"var b = true\nvar a = 1\n:env", "a                    INTEGER    1\nb                    BOOLEAN    true\n"},
+		{"var a = 1\n:reset\n:env", ""},
+		{":unknown", "unknown command :unknown, see :help\n"},
+	}
+
+	for _, tc := range tests {
+		var output bytes.Buffer
+
+		Console(strings.NewReader(tc.input+"\n"), &output)
+
+		got := strings.ReplaceAll(output.String(), ">> ", "")
+		if got != tc.expected {
+			t.Errorf("wrong output for %q. expected=%q. got=%q", tc.input, tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+package repl
+
+import "errors"
+
+// Raw terminal mode is only implemented for Linux; elsewhere the REPL falls
+// back to reading plain lines.
+type termState struct{}
+
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (*termState, error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
+
+func restore(fd int, state *termState) {}
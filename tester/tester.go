@@ -0,0 +1,193 @@
+// Package tester runs the test functions found in *_test.loop files.
+package tester
+
+import (
+	"fmt"
+	"github.com/kanersps/loop/ast"
+	"github.com/kanersps/loop/evaluator"
+	"github.com/kanersps/loop/models"
+	"github.com/kanersps/loop/object"
+	"github.com/kanersps/loop/parser"
+	"github.com/kanersps/loop/parser/lexer"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	fileSuffix = "_test.loop"
+	testPrefix = "test_"
+)
+
+// Result is the outcome of a single test function.
+type Result struct {
+	File     string
+	Name     string
+	Line     int
+	Column   int
+	Error    *models.Error // nil when the test passed
+	Duration time.Duration
+}
+
+func (r Result) Passed() bool {
+	return r.Error == nil
+}
+
+// Discover returns the test files under the given paths. Directories are
+// searched recursively, files are used as given.
+func Discover(paths []string) ([]string, error) {
+	var files []string
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !info.IsDir() && strings.HasSuffix(info.Name(), fileSuffix) {
+				files = append(files, file)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// RunFile runs every top-level test_ function in a file. Each test gets a
+// fresh environment in which the whole file is evaluated before the test is
+// called, so tests cannot see each other's state. Anything the tests print
+// goes to out.
+func RunFile(path string, out io.Writer) ([]Result, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	l := lexer.CreateFromReader(file)
+	p := parser.Create(l)
+	program := p.ParseProgram()
+
+	if l.Err() != nil {
+		return nil, l.Err()
+	}
+
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("%s:\n\t%s", path, strings.Join(p.Errors(), "\n\t"))
+	}
+
+	var results []Result
+
+	for _, test := range findTests(program) {
+		start := time.Now()
+
+		result := Result{
+			File:   path,
+			Name:   test.Value,
+			Line:   test.Token.Line,
+			Column: test.Token.Column,
+		}
+
+		result.Error = run(program, test.Value, out)
+		result.Duration = time.Since(start)
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func findTests(program *ast.Program) []*ast.Identifier {
+	var tests []*ast.Identifier
+
+	for _, statement := range program.Statements {
+		var name *ast.Identifier
+
+		switch statement := statement.(type) {
+		case *ast.VariableStatement:
+			if _, ok := statement.Value.(*ast.FunctionLiteral); ok {
+				name = statement.Name
+			}
+		case *ast.FunctionStatement:
+			name = statement.Function.Name
+		}
+
+		if name != nil && strings.HasPrefix(name.Value, testPrefix) {
+			tests = append(tests, name)
+		}
+	}
+
+	return tests
+}
+
+// run evaluates program in a fresh environment and calls the test function
+// name. A panic only fails this test rather than the whole run.
+func run(program *ast.Program, name string, out io.Writer) (failure *models.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			failure = &models.Error{Message: fmt.Sprint("PANIC: ", r)}
+		}
+	}()
+
+	env := object.NewEnvironment()
+	env.Streams = models.NewStreams(strings.NewReader(""), out, out)
+
+	if err, ok := evaluator.Eval(program, env).(*models.Error); ok {
+		return err
+	}
+
+	fn, _ := env.Get(name)
+
+	if err, ok := evaluator.ApplyFunction(fn, []models.Object{}, env).(*models.Error); ok {
+		return err
+	}
+
+	return nil
+}
+
+// Report writes a line for every test and returns whether all of them
+// passed.
+func Report(out io.Writer, results []Result, verbose bool) bool {
+	passed := true
+
+	for _, result := range results {
+		if result.Passed() {
+			if verbose {
+				fmt.Fprintf(out, "--- PASS: %s (%.2fs)\n", result.Name, result.Duration.Seconds())
+			}
+			continue
+		}
+
+		passed = false
+		fmt.Fprintf(out, "--- FAIL: %s (%s:%d:%d)\n", result.Name, result.File, result.Line, result.Column)
+
+		if result.Error.Line == 0 {
+			fmt.Fprintf(out, "    %s\n", result.Error.Message)
+		} else {
+			fmt.Fprintf(out, "    %s:%d:%d: %s\n", result.File, result.Error.Line, result.Error.Column, result.Error.Message)
+		}
+	}
+
+	return passed
+}
@@ -0,0 +1,104 @@
+package tester
+
+import (
+	"bytes"
+	"github.com/kanersps/loop/models"
+	"github.com/kanersps/loop/object/builtins"
+	"strings"
+	"testing"
+)
+
+func TestDiscover(t *testing.T) {
+	files, err := Discover([]string{"testdata"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 || !strings.HasSuffix(files[0], "math_test.loop") {
+		t.Errorf("wrong files discovered. got=%v", files)
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	results, err := RunFile("testdata/math_test.loop", &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		name   string
+		passed bool
+		line   int
+	}{
+		{"test_add", true, 7},
+		{"test_isolated", true, 12},
+		{"test_error", true, 17},
+		{"test_fails", false, 21},
+		{"test_declared", true, 29},
+	}
+
+	if len(results) != len(expected) {
+		t.Fatalf("wrong number of results. expected=%d. got=%d", len(expected), len(results))
+	}
+
+	for i, tc := range expected {
+		result := results[i]
+
+		if result.Name != tc.name || result.Passed() != tc.passed || result.Line != tc.line {
+			t.Errorf("wrong result %d. expected=%s passed=%t line=%d. got=%s passed=%t line=%d",
+				i, tc.name, tc.passed, tc.line, result.Name, result.Passed(), result.Line)
+		}
+	}
+
+	failure := results[3].Error
+	if failure.Line != 22 || failure.Column != 5 {
+		t.Errorf("wrong failure position. got=%d:%d", failure.Line, failure.Column)
+	}
+}
+
+func TestRunFile_Panic(t *testing.T) {
+	builtins.Functions["boom"] = &models.Builtin{Func: func(env *models.Environment, args ...models.Object) models.Object {
+		panic("boom")
+	}}
+	defer delete(builtins.Functions, "boom")
+
+	results, err := RunFile("testdata/panic.loop", &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("wrong number of results. expected=2. got=%d", len(results))
+	}
+
+	if results[0].Passed() || results[0].Error.Message != "PANIC: boom" {
+		t.Errorf("a panicking test should fail. got=%v", results[0].Error)
+	}
+
+	if !results[1].Passed() {
+		t.Errorf("tests after a panic should still run. got=%v", results[1].Error)
+	}
+}
+
+func TestReport(t *testing.T) {
+	results, err := RunFile("testdata/math_test.loop", &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if Report(&out, results, false) {
+		t.Errorf("report should fail when a test fails")
+	}
+
+	expected := "--- FAIL: test_fails (testdata/math_test.loop:21:5)\n" +
+		"    testdata/math_test.loop:22:5: ASSERTION FAILED: expected=3. got=2\n"
+
+	if out.String() != expected {
+		t.Errorf("wrong report. expected=%q. got=%q", expected, out.String())
+	}
+
+	if !Report(&out, append(results[:3], results[4]), true) {
+		t.Errorf("report should pass when all tests pass")
+	}
+}